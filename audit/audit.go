@@ -0,0 +1,313 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/model"
+)
+
+// GenesisHash is the previous hash of the first record of a chain.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+const maxRecordSize = 1024 * 1024
+
+type Outcome string
+
+const (
+	Success Outcome = "SUCCESS"
+	Failure Outcome = "FAILURE"
+	Denied  Outcome = "DENIED"
+)
+
+// Record is one line of the audit file.
+// Hash covers every other field, including PreviousHash, so changing, removing or reordering a record breaks the chain.
+type Record struct {
+	Sequence     int64     `json:"sequence"`
+	Time         time.Time `json:"time"`
+	Username     string    `json:"username"`
+	TraceId      string    `json:"traceId"`
+	Action       string    `json:"action"`
+	EntityUUID   string    `json:"entityUuid"`
+	Outcome      Outcome   `json:"outcome"`
+	Detail       string    `json:"detail,omitempty"`
+	PreviousHash string    `json:"previousHash"`
+	Hash         string    `json:"hash"`
+}
+
+// Logger appends audit records to a hash chained file.
+// It is kept apart from the log package: records are never sampled, redacted or rotated.
+type Logger struct {
+	path         string
+	key          []byte
+	mu           sync.Mutex
+	file         *os.File
+	sequence     int64
+	previousHash string
+}
+
+// NewLogger opens, or creates, the audit file at path and resumes its chain.
+// With a key the records are chained with HMAC-SHA256 instead of SHA-256,
+// so the chain can not be rebuilt by someone who can write the file but does not know the key.
+func NewLogger(path string, key []byte) (*Logger, error) {
+	if makeDirectoryAllError := os.MkdirAll(filepath.Dir(path), 0755); makeDirectoryAllError != nil {
+		return nil, makeDirectoryAllError
+	}
+	l := &Logger{
+		path:         path,
+		key:          key,
+		previousHash: GenesisHash,
+	}
+	if lastRecord, readLastRecordError := readLastRecord(path); readLastRecordError != nil {
+		return nil, readLastRecordError
+	} else if lastRecord != nil {
+		l.sequence = lastRecord.Sequence
+		l.previousHash = lastRecord.Hash
+	}
+	file, openFileError := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if openFileError != nil {
+		return nil, openFileError
+	}
+	l.file = file
+	return l, nil
+}
+
+// Log appends a record for an action on an entity, the username and traceId come from the context.
+func (l *Logger) Log(ctx context.Context, action string, entityUUID string, outcome Outcome, detail string) (*Record, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	record := &Record{
+		Time:       time.Now().UTC(),
+		Username:   contextString(ctx, constant.UsernameLogKey),
+		TraceId:    contextString(ctx, constant.TraceIdLogKey),
+		Action:     action,
+		EntityUUID: entityUUID,
+		Outcome:    outcome,
+		Detail:     detail,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil, errors.New("audit logger is closed")
+	}
+	record.Sequence = l.sequence + 1
+	record.PreviousHash = l.previousHash
+	hash, hashError := computeHash(record, l.key)
+	if hashError != nil {
+		return nil, hashError
+	}
+	record.Hash = hash
+
+	b, marshalError := json.Marshal(record)
+	if marshalError != nil {
+		return nil, marshalError
+	}
+	if _, writeError := l.file.Write(append(b, '\n')); writeError != nil {
+		return nil, writeError
+	}
+	// an audit record must survive a crash right after the action
+	if syncError := l.file.Sync(); syncError != nil {
+		return nil, syncError
+	}
+	l.sequence = record.Sequence
+	l.previousHash = record.Hash
+	return record, nil
+}
+
+// LogEntity is Log for an action on a model.BaseEntity.
+func (l *Logger) LogEntity(ctx context.Context, action string, entity *model.BaseEntity, outcome Outcome, detail string) (*Record, error) {
+	entityUUID := constant.EmptyString
+	if entity != nil {
+		entityUUID = entity.UUID
+	}
+	return l.Log(ctx, action, entityUUID, outcome, detail)
+}
+
+// Close closes the audit file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ChainError describes the first record breaking the chain of an audit file.
+type ChainError struct {
+	Line   int
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at line %d: %s", e.Line, e.Reason)
+}
+
+// Verify walks the chain of the audit file at path and returns the number of valid records.
+// The error is a *ChainError when the chain is broken. The key must be the one given to NewLogger.
+// Records cut from the end of the file leave a valid chain, compare the count or the last hash
+// with a copy kept elsewhere to detect it.
+func Verify(path string, key []byte) (int, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		return 0, openError
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	return VerifyReader(file, key)
+}
+
+// VerifyReader is Verify for an audit chain read from r.
+func VerifyReader(r io.Reader, key []byte) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	previousHash := GenesisHash
+	var sequence int64
+	line := 0
+	for scanner.Scan() {
+		line++
+		record := &Record{}
+		if unmarshalError := json.Unmarshal(scanner.Bytes(), record); unmarshalError != nil {
+			return line - 1, &ChainError{Line: line, Reason: "invalid record: " + unmarshalError.Error()}
+		}
+		if record.Sequence != sequence+1 {
+			return line - 1, &ChainError{Line: line, Reason: fmt.Sprintf("expected sequence %d, got %d", sequence+1, record.Sequence)}
+		}
+		if record.PreviousHash != previousHash {
+			return line - 1, &ChainError{Line: line, Reason: "previous hash does not match the previous record"}
+		}
+		hash, hashError := computeHash(record, key)
+		if hashError != nil {
+			return line - 1, hashError
+		}
+		if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return line - 1, &ChainError{Line: line, Reason: "hash does not match the record content"}
+		}
+		sequence = record.Sequence
+		previousHash = record.Hash
+	}
+	if scanError := scanner.Err(); scanError != nil {
+		return line, scanError
+	}
+	return line, nil
+}
+
+// computeHash hashes the JSON encoding of the record without its own hash.
+func computeHash(record *Record, key []byte) (string, error) {
+	unsigned := *record
+	unsigned.Hash = constant.EmptyString
+	b, marshalError := json.Marshal(unsigned)
+	if marshalError != nil {
+		return constant.EmptyString, marshalError
+	}
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(b)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readLastRecord returns the last record of the audit file, nil when the file does not exist or is empty.
+// A last line left half-written by a crash is truncated, the chain resumes from the record before it.
+func readLastRecord(path string) (*Record, error) {
+	file, openError := os.Open(path)
+	if os.IsNotExist(openError) {
+		return nil, nil
+	}
+	if openError != nil {
+		return nil, openError
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var previousLine, lastLine []byte
+	var offset, lastLineOffset int64
+	isLastLineComplete := true
+	for {
+		line, readError := reader.ReadBytes('\n')
+		if len(line) > maxRecordSize {
+			return nil, bufio.ErrTooLong
+		}
+		if trimmed := bytes.TrimRight(line, "\n"); len(trimmed) > 0 {
+			previousLine, lastLine = lastLine, append(previousLine[:0], trimmed...)
+			lastLineOffset = offset
+			isLastLineComplete = readError == nil
+		}
+		offset += int64(len(line))
+		if readError == io.EOF {
+			break
+		}
+		if readError != nil {
+			return nil, readError
+		}
+	}
+	if lastLine == nil {
+		return nil, nil
+	}
+	record := &Record{}
+	unmarshalError := json.Unmarshal(lastLine, record)
+	if unmarshalError == nil && isLastLineComplete {
+		return record, nil
+	}
+	if unmarshalError == nil {
+		// the record was written but its line break was not
+		return record, appendLineBreak(path)
+	}
+	if isLastLineComplete {
+		return nil, fmt.Errorf("can not resume audit chain of %s: %w", path, unmarshalError)
+	}
+	if truncateError := os.Truncate(path, lastLineOffset); truncateError != nil {
+		return nil, fmt.Errorf("can not truncate the half-written record of %s: %w", path, truncateError)
+	}
+	if previousLine == nil {
+		return nil, nil
+	}
+	record = &Record{}
+	if unmarshalError = json.Unmarshal(previousLine, record); unmarshalError != nil {
+		return nil, fmt.Errorf("can not resume audit chain of %s: %w", path, unmarshalError)
+	}
+	return record, nil
+}
+
+// appendLineBreak ends the last line of the audit file, so the next record starts on its own line
+func appendLineBreak(path string) error {
+	file, openFileError := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if openFileError != nil {
+		return openFileError
+	}
+	_, writeError := file.Write([]byte{'\n'})
+	if closeError := file.Close(); writeError == nil {
+		writeError = closeError
+	}
+	return writeError
+}
+
+func contextString(ctx context.Context, key constant.LogKey) string {
+	if value, isString := ctx.Value(key).(string); isString {
+		return value
+	}
+	return constant.EmptyString
+}
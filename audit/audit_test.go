@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/model"
+)
+
+func writeRecords(t *testing.T, path string, key []byte, actions ...string) {
+	logger, err := NewLogger(path, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ctx := context.WithValue(context.Background(), constant.UsernameLogKey, "loc")
+	for _, action := range actions {
+		if _, err := logger.LogEntity(ctx, action, &model.BaseEntity{UUID: "room-1"}, Success, ""); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func TestLogger_Verify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	key := []byte("secret")
+	writeRecords(t, path, key, "CREATE_ROOM", "ADD_MEMBER")
+	// reopening resumes the chain
+	writeRecords(t, path, key, "DELETE_ROOM")
+
+	count, err := Verify(path, key)
+	if err != nil || count != 3 {
+		t.Fatalf("Expected 3 valid records, got %d: %v", count, err)
+	}
+	if _, err := Verify(path, []byte("other key")); err == nil {
+		t.Errorf("Expected the verification to fail with another key")
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   int
+	}{
+		{
+			name: "Record changed",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"outcome":"SUCCESS"`, `"outcome":"FAILURE"`, 1)
+				return lines
+			},
+			line: 2,
+		}, {
+			name: "Record removed",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			line: 2,
+		}, {
+			name: "Records swapped",
+			tamper: func(lines []string) []string {
+				lines[0], lines[1] = lines[1], lines[0]
+				return lines
+			},
+			line: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			writeRecords(t, path, nil, "A", "B", "C")
+			b, _ := os.ReadFile(path)
+			lines := tc.tamper(strings.Split(strings.TrimSpace(string(b)), "\n"))
+			_ = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+			_, err := Verify(path, nil)
+			var chainError *ChainError
+			if !errors.As(err, &chainError) {
+				t.Fatalf("Expected a chain error, got %v", err)
+			}
+			if chainError.Line != tc.line {
+				t.Errorf("Expected the chain to break at line %d, got %d", tc.line, chainError.Line)
+			}
+		})
+	}
+}
+
+func TestNewLogger_HalfWrittenRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeRecords(t, path, nil, "CREATE_ROOM", "ADD_MEMBER")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	testCases := []struct {
+		name    string
+		content string
+		count   int
+	}{
+		{name: "Record cut", content: lines[0] + lines[1][:len(lines[1])/2], count: 2},
+		{name: "Line break missing", content: strings.TrimSuffix(string(b), "\n"), count: 3},
+		{name: "First record cut", content: lines[0][:10], count: 1},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			crashed := filepath.Join(t.TempDir(), "audit.log")
+			if err := os.WriteFile(crashed, []byte(testCase.content), 0600); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			// the chain resumes from the last complete record
+			writeRecords(t, crashed, nil, "DELETE_ROOM")
+			count, err := Verify(crashed, nil)
+			if err != nil || count != testCase.count {
+				t.Fatalf("Expected %d valid records, got %d: %v", testCase.count, count, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Command logq searches the log files written by the log package, in the text, JSON or logfmt format,
+// including the rotated and gzipped ones.
+//
+// Usage:
+//
+//	logq [flags] [file or directory ...]
+//
+// Without arguments the directory of LOG_DIRECTORY, "./service_log/" by default, is searched.
+//
+//	logq -trace 3f0c... -format json
+//	logq -level ERROR -since 2h -grep "payment|refund"
+//	logq -user alice -follow
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
+)
+
+func main() {
+	if runError := run(os.Args[1:]); runError != nil {
+		fmt.Fprintln(os.Stderr, "logq: "+runError.Error())
+		os.Exit(2)
+	}
+}
+
+func run(args []string) error {
+	config := log.ConfigFromEnvironment()
+	flags := flag.NewFlagSet("logq", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: logq [flags] [file or directory ...]")
+		flags.PrintDefaults()
+	}
+	traceId := flags.String("trace", constant.EmptyString, "only the records of this traceId")
+	username := flags.String("user", constant.EmptyString, "only the records of this username")
+	level := flags.String("level", constant.EmptyString, "minimum level: TRACE, DEBUG, INFO, WARN, ERROR or FATAL")
+	since := flags.String("since", constant.EmptyString, `records from this time, e.g. "2024-05-03 10:00:00", RFC 3339, or a duration ago like "2h"`)
+	until := flags.String("until", constant.EmptyString, "records until this time, same formats as -since")
+	pattern := flags.String("grep", constant.EmptyString, "regular expression the message must match")
+	format := flags.String("format", TableFormat, "output format: table, json or raw")
+	timeZone := flags.String("timezone", config.TimeZone, "time zone of the timestamps of the text files")
+	follow := flags.Bool("follow", false, "keep printing the records appended to the files")
+	flags.BoolVar(follow, "f", false, "shorthand for -follow")
+	if parseError := flags.Parse(args); parseError != nil {
+		return parseError
+	}
+
+	config.TimeZone = *timeZone
+	location := config.Location()
+	filter := log.RecordFilter{
+		TraceId:  *traceId,
+		Username: *username,
+	}
+	if *level != constant.EmptyString {
+		minimum, parseLevelError := log.ParseLevel(*level)
+		if parseLevelError != nil {
+			return parseLevelError
+		}
+		filter.Level = minimum
+	}
+	var parseTimeError error
+	if filter.From, parseTimeError = parseTime(*since, location); parseTimeError != nil {
+		return parseTimeError
+	}
+	if filter.To, parseTimeError = parseTime(*until, location); parseTimeError != nil {
+		return parseTimeError
+	}
+	if *pattern != constant.EmptyString {
+		message, compileError := regexp.Compile(*pattern)
+		if compileError != nil {
+			return compileError
+		}
+		filter.Message = message
+	}
+	printer, newPrinterError := NewPrinter(*format, os.Stdout)
+	if newPrinterError != nil {
+		return newPrinterError
+	}
+	printer.Streaming = *follow
+	defer printer.Flush()
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{config.Directory}
+	}
+	query := &Query{
+		Paths:    paths,
+		Location: location,
+		Filter:   filter,
+		Print:    printer.Print,
+	}
+	if !*follow {
+		return query.Run()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return query.Follow(ctx, followInterval)
+}
+
+const followInterval = 500 * time.Millisecond
+
+// parseTime reads a -since or -until value, empty means no limit.
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if value == constant.EmptyString {
+		return time.Time{}, nil
+	}
+	if ago, parseDurationError := time.ParseDuration(value); parseDurationError == nil {
+		return time.Now().Add(-ago), nil
+	}
+	if t, parseError := time.Parse(time.RFC3339, value); parseError == nil {
+		return t, nil
+	}
+	for _, layout := range []string{constant.YyyyMmDdHhMmSsFormat, "2006-01-02"} {
+		if t, parseError := time.ParseInLocation(layout, value, location); parseError == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
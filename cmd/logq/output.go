@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	TableFormat = "table"
+	JSONFormat  = "json"
+	RawFormat   = "raw"
+
+	// tableFlushSize is the number of rows aligned together, so that a long search prints as it goes
+	tableFlushSize = 100
+)
+
+// Printer writes the matching entries in one of the output formats.
+type Printer struct {
+	// Streaming writes every table row right away, at the cost of the alignment of the columns
+	Streaming bool
+	format    string
+	out       io.Writer
+	table     *tabwriter.Writer
+	tableRows int
+	encoder   *json.Encoder
+}
+
+// NewPrinter creates a printer for the table, json or raw format.
+func NewPrinter(format string, out io.Writer) (*Printer, error) {
+	printer := &Printer{format: format, out: out}
+	switch format {
+	case TableFormat:
+		printer.table = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	case JSONFormat:
+		printer.encoder = json.NewEncoder(out)
+	case RawFormat:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected table, json or raw", format)
+	}
+	return printer, nil
+}
+
+// Print writes an entry: a row of the table, the JSON record, or the lines as found in the file.
+func (p *Printer) Print(entry *Entry) error {
+	switch p.format {
+	case TableFormat:
+		record := entry.Record
+		if p.tableRows == 0 {
+			if _, writeError := fmt.Fprintln(p.table, "TIME\tLEVEL\tTRACE ID\tUSERNAME\tMESSAGE"); writeError != nil {
+				return writeError
+			}
+		}
+		// the table keeps one line per record
+		message, _, _ := strings.Cut(record.Message, "\n")
+		if _, writeError := fmt.Fprintf(p.table, "%s\t%s\t%s\t%s\t%s\n",
+			record.Time.Format(time.RFC3339), record.Level, record.TraceId, record.Username, message); writeError != nil {
+			return writeError
+		}
+		p.tableRows++
+		if p.Streaming || p.tableRows%tableFlushSize == 0 {
+			return p.table.Flush()
+		}
+		return nil
+	case JSONFormat:
+		return p.encoder.Encode(entry.Record)
+	default:
+		_, writeError := fmt.Fprintln(p.out, strings.Join(entry.Lines, "\n"))
+		return writeError
+	}
+}
+
+// Flush writes the pending table rows.
+func (p *Printer) Flush() error {
+	if p.table == nil {
+		return nil
+	}
+	return p.table.Flush()
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/log"
+)
+
+const (
+	logExtension        = ".log"
+	compressedExtension = ".log.gz"
+)
+
+// Entry is a parsed record with the lines it was read from.
+type Entry struct {
+	Record *log.Record
+	Lines  []string
+	File   string
+}
+
+// Query reads the log files of Paths and prints the records matching the Filter.
+type Query struct {
+	// Paths are log files or directories holding them
+	Paths []string
+	// Location is the time zone of the text timestamps
+	Location *time.Location
+	Filter   log.RecordFilter
+	Print    func(entry *Entry) error
+	files    map[string]*followedFile
+}
+
+// followedFile is the read position of a file in follow mode, -1 for the files not followed.
+// Its scanner holds the last entry until a line starts a new record or the file stops growing,
+// so that the lines of an entry read over several polls are printed together.
+type followedFile struct {
+	info    os.FileInfo
+	offset  int64
+	scanner *entryScanner
+}
+
+// Run prints the matching records of every file, oldest file first.
+func (q *Query) Run() error {
+	files, listError := q.listFiles()
+	if listError != nil {
+		return listError
+	}
+	for _, file := range files {
+		scanner := q.newScanner(file.path)
+		if _, readError := q.readFile(file.path, 0, scanner); readError != nil {
+			return readError
+		}
+		if flushError := scanner.flush(); flushError != nil {
+			return flushError
+		}
+	}
+	return nil
+}
+
+// Follow prints the matching records of every file, then the records appended to them until the context ends.
+// The files created later are read from their beginning, except the gzipped ones which are
+// compressed rotations of files already read.
+// The last entry of a file is printed once a new record follows it or the file did not grow for an interval.
+func (q *Query) Follow(ctx context.Context, interval time.Duration) error {
+	if startError := q.startFollowing(); startError != nil {
+		return startError
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return q.flushPending()
+		case <-ticker.C:
+			if pollError := q.poll(); pollError != nil {
+				return pollError
+			}
+		}
+	}
+}
+
+// startFollowing reads the files and records their positions.
+func (q *Query) startFollowing() error {
+	q.files = make(map[string]*followedFile)
+	files, listError := q.listFiles()
+	if listError != nil {
+		return listError
+	}
+	for _, file := range files {
+		state := &followedFile{info: file.info, scanner: q.newScanner(file.path)}
+		offset, readError := q.readFile(file.path, 0, state.scanner)
+		if readError != nil {
+			return readError
+		}
+		state.offset = offset
+		if strings.HasSuffix(file.path, compressedExtension) {
+			// compressed files do not grow
+			state.offset = -1
+			if flushError := state.scanner.flush(); flushError != nil {
+				return flushError
+			}
+		}
+		q.files[file.path] = state
+	}
+	return nil
+}
+
+// flushPending prints the entries held for the files followed.
+func (q *Query) flushPending() error {
+	for _, state := range q.files {
+		if flushError := state.scanner.flush(); flushError != nil {
+			return flushError
+		}
+	}
+	return nil
+}
+
+// poll reads what was appended since the last poll.
+func (q *Query) poll() error {
+	files, listError := q.listFiles()
+	if listError != nil {
+		return listError
+	}
+	listed := make(map[string]os.FileInfo, len(files))
+	for _, file := range files {
+		listed[file.path] = file.info
+	}
+	previous := q.files
+	q.files = make(map[string]*followedFile, len(files))
+	for _, file := range files {
+		state, isKnown := previous[file.path]
+		if !isKnown || !os.SameFile(state.info, file.info) {
+			state = q.renamedFile(previous, file, listed)
+		}
+		q.files[file.path] = state
+		isQuiet := file.info.Size() == state.info.Size()
+		state.info = file.info
+		state.scanner.file = file.path
+		if state.offset < 0 {
+			continue
+		}
+		if isQuiet || file.info.Size() < state.offset {
+			// the entry held is complete, unless its writer paused for a whole interval
+			if flushError := state.scanner.flush(); flushError != nil {
+				return flushError
+			}
+		}
+		if file.info.Size() < state.offset {
+			// truncated
+			state.offset = 0
+		}
+		if file.info.Size() == state.offset {
+			continue
+		}
+		offset, readError := q.readFile(file.path, state.offset, state.scanner)
+		if readError != nil {
+			return readError
+		}
+		state.offset = offset
+	}
+	// the entries held for the files removed in the meantime
+	followed := make(map[*entryScanner]bool, len(q.files))
+	for _, state := range q.files {
+		followed[state.scanner] = true
+	}
+	for _, state := range previous {
+		if followed[state.scanner] {
+			continue
+		}
+		if flushError := state.scanner.flush(); flushError != nil {
+			return flushError
+		}
+	}
+	return nil
+}
+
+// renamedFile returns the position of a file found under a new path.
+// A file renamed by the size rotation keeps its position, a new plain file is read from the beginning,
+// a new gzipped file is skipped.
+func (q *Query) renamedFile(previous map[string]*followedFile, file listedFile, listed map[string]os.FileInfo) *followedFile {
+	for path, state := range previous {
+		if path == file.path || !os.SameFile(state.info, file.info) {
+			continue
+		}
+		if current, isListed := listed[path]; !isListed || !os.SameFile(current, state.info) {
+			return &followedFile{info: state.info, offset: state.offset, scanner: state.scanner}
+		}
+	}
+	scanner := q.newScanner(file.path)
+	if strings.HasSuffix(file.path, compressedExtension) {
+		return &followedFile{info: file.info, offset: -1, scanner: scanner}
+	}
+	return &followedFile{info: file.info, scanner: scanner}
+}
+
+// readFile scans the lines from offset and returns the offset after the last complete line.
+// The last entry is left in the scanner, see entryScanner.flush.
+func (q *Query) readFile(path string, offset int64, scanner *entryScanner) (int64, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		if errors.Is(openError, os.ErrNotExist) {
+			// rotated or removed in the meantime
+			return offset, nil
+		}
+		return offset, openError
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	isCompressed := strings.HasSuffix(path, ".gz")
+	if isCompressed {
+		gzipReader, gzipError := gzip.NewReader(file)
+		if gzipError != nil {
+			return offset, gzipError
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	} else if _, seekError := file.Seek(offset, io.SeekStart); seekError != nil {
+		return offset, seekError
+	}
+
+	bufferedReader := bufio.NewReaderSize(reader, 64*1024)
+	for {
+		line, readError := bufferedReader.ReadString('\n')
+		if readError == io.EOF {
+			// when following, an incomplete line is read again once it is complete
+			if line != "" && (q.files == nil || isCompressed) {
+				offset += int64(len(line))
+				if printError := scanner.scan(line); printError != nil {
+					return offset, printError
+				}
+			}
+			break
+		}
+		if readError != nil {
+			return offset, readError
+		}
+		offset += int64(len(line))
+		if printError := scanner.scan(strings.TrimRight(line, "\r\n")); printError != nil {
+			return offset, printError
+		}
+	}
+	return offset, nil
+}
+
+func (q *Query) newScanner(path string) *entryScanner {
+	return &entryScanner{file: path, location: q.Location, filter: q.Filter, print: q.Print}
+}
+
+// entryScanner groups the lines of a file into entries, the lines not starting a record belong to the previous one.
+type entryScanner struct {
+	file     string
+	location *time.Location
+	filter   log.RecordFilter
+	print    func(entry *Entry) error
+	current  *Entry
+}
+
+func (s *entryScanner) scan(line string) error {
+	record, parseError := log.ParseLine(line, s.location)
+	if parseError != nil {
+		if s.current != nil {
+			// stack traces and multi-line messages of the text format
+			s.current.Lines = append(s.current.Lines, line)
+			s.current.Record.Message += "\n" + line
+		}
+		return nil
+	}
+	if flushError := s.flush(); flushError != nil {
+		return flushError
+	}
+	s.current = &Entry{Record: record, Lines: []string{line}, File: s.file}
+	return nil
+}
+
+func (s *entryScanner) flush() error {
+	entry := s.current
+	s.current = nil
+	if entry == nil || !s.filter.Matches(entry.Record) {
+		return nil
+	}
+	return s.print(entry)
+}
+
+type listedFile struct {
+	path string
+	info os.FileInfo
+}
+
+// listFiles returns the log files of the paths, the oldest first.
+func (q *Query) listFiles() ([]listedFile, error) {
+	var files []listedFile
+	for _, path := range q.Paths {
+		info, statError := os.Stat(path)
+		if statError != nil {
+			return nil, statError
+		}
+		if !info.IsDir() {
+			files = append(files, listedFile{path: path, info: info})
+			continue
+		}
+		entries, readDirError := os.ReadDir(path)
+		if readDirError != nil {
+			return nil, readDirError
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, logExtension) || strings.HasSuffix(name, compressedExtension)) {
+				continue
+			}
+			entryInfo, infoError := entry.Info()
+			if infoError != nil {
+				// removed in the meantime
+				continue
+			}
+			files = append(files, listedFile{path: filepath.Join(path, name), info: entryInfo})
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].info.ModTime().Equal(files[j].info.ModTime()) {
+			return files[i].info.ModTime().Before(files[j].info.ModTime())
+		}
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
+)
+
+const textLines = "2024-05-03 10:00:00: INFO - [trace-1] [alice] 👉️ \tpayment created\n" +
+	"2024-05-03 10:00:01: ERROR - [trace-1] [alice] 👉️ \tpayment failed\n" +
+	"main.pay\n" +
+	"\t/src/main.go:12\n" +
+	"2024-05-03 10:00:02: INFO - [trace-2] [bob] 👉️ \troom joined\n"
+
+type collector struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (c *collector) print(entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, entry)
+	return nil
+}
+
+func (c *collector) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var messages []string
+	for _, entry := range c.entries {
+		messages = append(messages, entry.Record.Message)
+	}
+	return messages
+}
+
+func writeFile(t *testing.T, path string, content string, modTime time.Time) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to date %s: %v", path, err)
+	}
+}
+
+func writeGzipFile(t *testing.T, path string, content string, modTime time.Time) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	gzipWriter := gzip.NewWriter(file)
+	_, _ = gzipWriter.Write([]byte(content))
+	_ = gzipWriter.Close()
+	_ = file.Close()
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to date %s: %v", path, err)
+	}
+}
+
+func TestQuery_Run(t *testing.T) {
+	directory := t.TempDir()
+	now := time.Now()
+	writeFile(t, filepath.Join(directory, "svc_log_2024_5_3.log"), textLines, now)
+	// a rotated JSON file, older than the current one
+	jsonLine, _ := (&log.JSONFormatter{}).Format(&log.Record{
+		Time: time.Date(2024, 5, 3, 2, 0, 0, 0, time.UTC), Level: constant.Warn, TraceId: "trace-1", Message: "payment retried",
+	})
+	writeGzipFile(t, filepath.Join(directory, "svc_log_2024_5_3-20240503T020000.000.log.gz"), string(jsonLine), now.Add(-time.Hour))
+	writeFile(t, filepath.Join(directory, "notes.txt"), "not a log", now)
+
+	output := &collector{}
+	query := &Query{
+		Paths:    []string{directory},
+		Location: time.UTC,
+		Filter:   log.RecordFilter{TraceId: "trace-1"},
+		Print:    output.print,
+	}
+	if err := query.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	messages := output.messages()
+	expected := []string{"payment retried", "payment created", "payment failed\nmain.pay\n\t/src/main.go:12"}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], messages[i])
+		}
+	}
+	if lines := output.entries[2].Lines; len(lines) != 3 {
+		t.Errorf("Expected the stack lines in the raw lines, got %q", lines)
+	}
+
+	output = &collector{}
+	query.Filter = log.RecordFilter{
+		Level:   constant.Info,
+		From:    time.Date(2024, 5, 3, 10, 0, 1, 0, time.UTC),
+		Message: regexp.MustCompile("^room"),
+	}
+	query.Print = output.print
+	if err := query.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if messages := output.messages(); len(messages) != 1 || messages[0] != "room joined" {
+		t.Errorf("Expected the room record only, got %q", messages)
+	}
+}
+
+func TestQuery_Follow(t *testing.T) {
+	directory := t.TempDir()
+	current := filepath.Join(directory, "svc.log")
+	writeFile(t, current, "", time.Now())
+
+	output := &collector{}
+	query := &Query{Paths: []string{directory}, Location: time.UTC, Print: output.print}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- query.Follow(ctx, 10*time.Millisecond)
+	}()
+	waitMessages := func(count int) []string {
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if messages := output.messages(); len(messages) >= count {
+				return messages
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %d records, got %q", count, output.messages())
+		return nil
+	}
+
+	file, _ := os.OpenFile(current, os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = file.WriteString("2024-05-03 10:00:00: INFO - [trace-1] [alice] 👉️ \tfirst\n2024-05-03 10:00:00: INFO - [trace-1] [alice] 👉️ \tpar")
+	waitMessages(1)
+	_, _ = file.WriteString("tial\n")
+	_ = file.Close()
+	waitMessages(2)
+
+	// size rotation: the current file is renamed and a new one is created
+	if err := os.Rename(current, filepath.Join(directory, "svc-20240503T100000.000.log")); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	writeFile(t, current, "2024-05-03 10:00:05: INFO - [trace-1] [alice] 👉️ \tafter rotation\n", time.Now())
+	waitMessages(3)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+	messages := output.messages()
+	expected := []string{"first", "partial", "after rotation"}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %q without duplicates, got %q", expected, messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], messages[i])
+		}
+	}
+}
+
+func TestQuery_FollowSplitEntry(t *testing.T) {
+	directory := t.TempDir()
+	current := filepath.Join(directory, "svc.log")
+	writeFile(t, current, "2024-05-03 10:00:00: ERROR - [trace-1] [alice] 👉️ \tpanic\ngoroutine 1 [running]:\n", time.Now())
+	output := &collector{}
+	query := &Query{Paths: []string{directory}, Location: time.UTC, Print: output.print}
+	if err := query.startFollowing(); err != nil {
+		t.Fatalf("Failed to read the files: %v", err)
+	}
+	appendLines := func(lines string) {
+		file, _ := os.OpenFile(current, os.O_APPEND|os.O_WRONLY, 0644)
+		_, _ = file.WriteString(lines)
+		_ = file.Close()
+	}
+	appendLines("main.main()\n\t/src/main.go:12\n")
+	if err := query.poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if messages := output.messages(); len(messages) != 0 {
+		t.Fatalf("Expected the entry to be held while the file grows, got %q", messages)
+	}
+	appendLines("2024-05-03 10:00:01: INFO - [trace-2] [alice] 👉️ \tnext\n")
+	if err := query.poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	expected := "panic\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:12"
+	if messages := output.messages(); len(messages) != 1 || messages[0] != expected {
+		t.Fatalf("Expected the whole stack trace once the next record started, got %q", messages)
+	}
+	if err := query.poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if messages := output.messages(); len(messages) != 2 || messages[1] != "next" {
+		t.Errorf("Expected the last entry once the file stopped growing, got %q", messages)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	location := time.FixedZone("ICT", 7*60*60)
+	parsed, err := parseTime("2024-05-03 10:00:00", location)
+	if err != nil || !parsed.Equal(time.Date(2024, 5, 3, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the time in the location, got %v %v", parsed, err)
+	}
+	parsed, err = parseTime("2h", location)
+	if err != nil || time.Since(parsed) < 2*time.Hour || time.Since(parsed) > 2*time.Hour+time.Minute {
+		t.Errorf("Expected two hours ago, got %v %v", parsed, err)
+	}
+	if _, err = parseTime("yesterday", location); err == nil {
+		t.Errorf("Expected an error for an invalid time")
+	}
+}
@@ -3,10 +3,12 @@ package constant
 type LogLevelType string
 
 const (
+	Trace LogLevelType = "TRACE"
 	Info  LogLevelType = "INFO"
 	Warn  LogLevelType = "WARN"
 	Error LogLevelType = "ERROR"
 	Debug LogLevelType = "DEBUG"
+	Fatal LogLevelType = "FATAL"
 )
 
 type ErrorEnums struct {
@@ -18,6 +20,10 @@ const BaseApiPath = "/roommate/api/v1"
 
 const LogFileFolder = "./service_log/"
 const LogFileLocation = "room_mate_finance_service_log_%d_%d_%d.log"
+
+// LogFileNameTemplate placeholders: {service}, {year}, {month} and {day}
+const LogFileNameTemplate = "{service}_log_{year}_{month}_{day}.log"
+const DefaultLogTimeZone = "Asia/Ho_Chi_Minh"
 const DeltaPositive = 0.5
 const DeltaNegative = -0.5
 const YyyyMmDdHhMmSsFormat = "2006-01-02 15:04:05"
@@ -0,0 +1,249 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBatchBufferSize     = 1000
+	defaultBatchFlushInterval  = 2 * time.Second
+	defaultBatchFlushThreshold = 10
+	defaultBatchMaxRetries     = 2
+	defaultBatchRetryBackoff   = 500 * time.Millisecond
+	defaultBatchMaxBackoff     = 30 * time.Second
+	batchErrorBufferSize       = 100
+)
+
+// ErrBatchBufferFull is reported when a record is dropped because the remote sink can not keep up.
+var ErrBatchBufferFull = errors.New("batch buffer is full, record dropped")
+
+// BatchOptions controls how a remote sink batches records, the same way splunk.Writer does.
+type BatchOptions struct {
+	// How often the buffered records are sent
+	FlushInterval time.Duration
+	// How many records are buffered before they are sent
+	FlushThreshold int
+	// Max number of retries of a failed batch, -1 disables the retries
+	MaxRetries int
+	// Delay before the first retry, 500ms by default. It doubles on every retry up to MaxRetryBackoff, 30s by default,
+	// a Retry-After header of the remote takes precedence but is capped by MaxRetryBackoff too.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// BufferSize is the number of records waiting to be batched, newer records are dropped when it is full
+	BufferSize int
+}
+
+// sendBatch delivers records and returns the ones worth retrying along with the error, if any.
+// A failure of the whole request returns every record, a permanent failure returns none.
+type sendBatch func(records []*Record) ([]*Record, error)
+
+// retryAfterError is a failure the remote asked to retry after a delay, with a Retry-After header
+type retryAfterError struct {
+	delay time.Duration
+	err   error
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// withRetryAfter attaches the Retry-After delay of the response to its error, if any
+func withRetryAfter(err error, response *http.Response) error {
+	header := response.Header.Get("Retry-After")
+	if seconds, atoiError := strconv.Atoi(header); atoiError == nil && seconds > 0 {
+		return &retryAfterError{delay: time.Duration(seconds) * time.Second, err: err}
+	}
+	if date, parseError := http.ParseTime(header); parseError == nil && time.Until(date) > 0 {
+		return &retryAfterError{delay: time.Until(date), err: err}
+	}
+	return err
+}
+
+// batcher queues records and hands them in batches to send from a single goroutine.
+// Unlike splunk.Writer a batch is sent before the next one is collected, so a slow remote can not pile up goroutines.
+type batcher struct {
+	once    sync.Once
+	queue   chan *Record
+	errors  chan error
+	dropped atomic.Int64
+	// pending counts the records queued or being sent
+	pending atomic.Int64
+	// mu guards closed, add holds it for reading so close never closes the queue under a pending add
+	mu       sync.RWMutex
+	closed   bool
+	done     chan struct{}
+	stopOnce sync.Once
+	// stopRetries is closed when close gives up, so that no retry delay outlives it
+	stopRetries chan struct{}
+}
+
+// add queues a copy of the record without blocking, starting the batching goroutine on first use.
+func (b *batcher) add(options BatchOptions, send sendBatch, record *Record) error {
+	stored := *record
+	// the fields slice may be reused by the caller
+	stored.Fields = append([]Field(nil), record.Fields...)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrSinkClosed
+	}
+	b.init(options, send)
+	select {
+	case b.queue <- &stored:
+		b.pending.Add(1)
+		return nil
+	default:
+		b.dropped.Add(1)
+		return ErrBatchBufferFull
+	}
+}
+
+// errorChannel returns the channel of the batches that could not be delivered.
+func (b *batcher) errorChannel(options BatchOptions, send sendBatch) <-chan error {
+	b.init(options, send)
+	return b.errors
+}
+
+// close stops accepting records and waits until the queued ones are sent or the context ends.
+// The records not sent at the end of the context are counted as dropped.
+func (b *batcher) close(ctx context.Context, options BatchOptions, send sendBatch) error {
+	b.init(options, send)
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		b.stopOnce.Do(func() {
+			b.dropped.Add(b.pending.Load())
+			close(b.stopRetries)
+		})
+		return ctx.Err()
+	}
+}
+
+// droppedCount returns the number of records dropped because the buffer was full or close gave up.
+func (b *batcher) droppedCount() int64 {
+	return b.dropped.Load()
+}
+
+func (b *batcher) init(options BatchOptions, send sendBatch) {
+	b.once.Do(func() {
+		if options.FlushInterval <= 0 {
+			options.FlushInterval = defaultBatchFlushInterval
+		}
+		if options.FlushThreshold <= 0 {
+			options.FlushThreshold = defaultBatchFlushThreshold
+		}
+		if options.MaxRetries == 0 {
+			options.MaxRetries = defaultBatchMaxRetries
+		}
+		if options.BufferSize <= 0 {
+			options.BufferSize = defaultBatchBufferSize
+		}
+		if options.RetryBackoff <= 0 {
+			options.RetryBackoff = defaultBatchRetryBackoff
+		}
+		if options.MaxRetryBackoff <= 0 {
+			options.MaxRetryBackoff = defaultBatchMaxBackoff
+		}
+		b.queue = make(chan *Record, options.BufferSize)
+		b.errors = make(chan error, batchErrorBufferSize)
+		b.done = make(chan struct{})
+		b.stopRetries = make(chan struct{})
+		go b.listen(options, send)
+	})
+}
+
+func (b *batcher) listen(options BatchOptions, send sendBatch) {
+	ticker := time.NewTicker(options.FlushInterval)
+	defer ticker.Stop()
+	buffer := make([]*Record, 0, options.FlushThreshold)
+	flush := func() {
+		b.send(buffer, options, send)
+		buffer = make([]*Record, 0, options.FlushThreshold)
+	}
+	for {
+		select {
+		case <-ticker.C:
+			if len(buffer) > 0 {
+				flush()
+			}
+		case record, isOpen := <-b.queue:
+			if !isOpen {
+				if len(buffer) > 0 {
+					flush()
+				}
+				close(b.done)
+				return
+			}
+			buffer = append(buffer, record)
+			if len(buffer) >= options.FlushThreshold {
+				flush()
+			}
+		}
+	}
+}
+
+// send delivers a batch, retrying the failed records with a backoff, and reports the last error once the retries
+// are exhausted or close gave up.
+func (b *batcher) send(records []*Record, options BatchOptions, send sendBatch) {
+	retryRecords, sendError := send(records)
+	for i := 0; sendError != nil && len(retryRecords) > 0 && i < options.MaxRetries && b.waitRetry(retryDelay(options, sendError, i)); i++ {
+		retryRecords, sendError = send(retryRecords)
+	}
+	if sendError != nil {
+		b.report(sendError)
+	}
+	b.pending.Add(-int64(len(records)))
+}
+
+// waitRetry waits for the retry delay, it returns false when close gave up waiting for the records
+func (b *batcher) waitRetry(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.stopRetries:
+		return false
+	}
+}
+
+// retryDelay returns how long to wait before the retry following the failed attempt, counted from 0, at most MaxRetryBackoff.
+// The delay doubles on every attempt and is jittered, so that sinks restarted together do not retry in lockstep.
+func retryDelay(options BatchOptions, err error, attempt int) time.Duration {
+	var retryAfter *retryAfterError
+	if errors.As(err, &retryAfter) {
+		return min(retryAfter.delay, options.MaxRetryBackoff)
+	}
+	delay := options.MaxRetryBackoff
+	if backoff := options.RetryBackoff << attempt; attempt < 32 && backoff > 0 && backoff < delay {
+		delay = backoff
+	}
+	// between half and all of the delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (b *batcher) report(err error) {
+	select {
+	case b.errors <- err:
+	// Don't block in case no one is listening or the errors channel is full
+	default:
+	}
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// Caller locates the code that produced a record.
+type Caller struct {
+	Function string `json:"function,omitempty"` // fully qualified function name, e.g. "github.com/org/app/service.(*Room).Create"
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+}
+
+// callerAt resolves the caller skip frames above the function calling callerAt.
+func callerAt(skip int) Caller {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return Caller{}
+	}
+	caller := Caller{
+		File: file,
+		Line: line,
+	}
+	if function := runtime.FuncForPC(pc); function != nil {
+		caller.Function = function.Name()
+	}
+	return caller
+}
+
+// callerFromPC resolves the caller of a program counter, as found in slog.Record.
+func callerFromPC(pc uintptr) Caller {
+	if pc == 0 {
+		return Caller{}
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return Caller{
+		Function: frame.Function,
+		File:     frame.File,
+		Line:     frame.Line,
+	}
+}
+
+// Package returns the import path of the package of the caller.
+func (c Caller) Package() string {
+	function := c.Function
+	lastSlash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[lastSlash+1:], "."); dot >= 0 {
+		return function[:lastSlash+1+dot]
+	}
+	return function
+}
+
+// String returns the short "directory/file.go:line" form, empty when the caller is unknown.
+func (c Caller) String() string {
+	if c.File == constant.EmptyString {
+		return constant.EmptyString
+	}
+	return filepath.Join(filepath.Base(filepath.Dir(c.File)), filepath.Base(c.File)) + ":" + strconv.Itoa(c.Line)
+}
+
+const maxStackDepth = 64
+
+// captureStack formats the stack skip frames above the function calling captureStack.
+// When called while panicking, the frames of the panic machinery are removed and the caller is the frame that panicked.
+func captureStack(skip int) (stack string, caller Caller) {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var collected []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			// everything collected so far belongs to the recovery
+			collected = collected[:0]
+		} else {
+			collected = append(collected, frame)
+		}
+		if !more {
+			break
+		}
+	}
+
+	var sb strings.Builder
+	for i, frame := range collected {
+		if i == 0 {
+			caller = Caller{Function: frame.Function, File: frame.File, Line: frame.Line}
+		}
+		sb.WriteString(frame.Function + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line) + "\n")
+	}
+	return sb.String(), caller
+}
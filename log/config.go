@@ -0,0 +1,188 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const defaultMemoryRecords = 1000
+
+// Config holds the settings shared by the outputs of the default logger.
+type Config struct {
+	// TimeZone is the IANA name of the time zone used for timestamps and file names, e.g. "Asia/Ho_Chi_Minh"
+	TimeZone string
+	// Directory holding the log files
+	Directory string
+	// FileNameTemplate is the daily file name, see constant.LogFileNameTemplate for the placeholders
+	FileNameTemplate string
+	// ServiceName replaces the {service} placeholder and identifies the service in the outputs
+	ServiceName string
+	// ConsoleFormat is the encoding of the console output: "text", "json" or "logfmt".
+	// Empty keeps the colored console output.
+	ConsoleFormat string
+	// FileFormat is the encoding of the log files: "text", "json" or "logfmt"
+	FileFormat string
+	// Level is the minimum level of every output, empty keeps INFO on the console and everything elsewhere
+	Level constant.LogLevelType
+	// SamplingFirst enables sampling when positive, see Sampler
+	SamplingFirst      int
+	SamplingThereafter int
+	SamplingInterval   time.Duration
+	// Redaction is the mask mode of the sensitive data, MaskNone disables the redaction
+	Redaction MaskMode
+	// MemoryRecords is the number of records kept in memory, 0 disables the memory output
+	MemoryRecords int
+	// SyslogAddress enables the syslog output when set, SyslogNetwork is "udp", "tcp" or "tls"
+	SyslogNetwork string
+	SyslogAddress string
+	// LokiURL enables the Loki output when set, e.g. http://loki:3100
+	LokiURL string
+	// ElasticsearchURL enables the Elasticsearch output when set, e.g. http://elasticsearch:9200
+	ElasticsearchURL string
+}
+
+// DefaultConfig returns the configuration used when nothing is set in the environment.
+// The service name defaults to the name of the running executable.
+func DefaultConfig() Config {
+	return Config{
+		TimeZone:         constant.DefaultLogTimeZone,
+		Directory:        constant.LogFileFolder,
+		FileNameTemplate: constant.LogFileNameTemplate,
+		ServiceName:      executableName(),
+		FileFormat:       TextFormat,
+		Redaction:        MaskFull,
+		MemoryRecords:    defaultMemoryRecords,
+		SyslogNetwork:    SyslogTCP,
+	}
+}
+
+// ConfigFromEnvironment returns DefaultConfig overridden by the environment.
+// LOG_TIMEZONE: "Asia/Ho_Chi_Minh",
+// LOG_DIRECTORY: "./service_log/",
+// LOG_FILE_NAME_TEMPLATE: "{service}_log_{year}_{month}_{day}.log",
+// SERVICE_NAME: "{your-service-name}",
+// LOG_CONSOLE_FORMAT: "text" | "json" | "logfmt",
+// LOG_FILE_FORMAT: "text" | "json" | "logfmt",
+// LOG_LEVEL: "TRACE" | "DEBUG" | "INFO" | "WARN" | "ERROR" | "FATAL",
+// LOG_SAMPLING_FIRST: "100",
+// LOG_SAMPLING_THEREAFTER: "100",
+// LOG_SAMPLING_INTERVAL: "1m",
+// LOG_REDACTION: "full" | "partial" | "hash" | "none",
+// LOG_MEMORY_RECORDS: "1000",
+// LOG_SYSLOG_NETWORK: "udp" | "tcp" | "tls",
+// LOG_SYSLOG_ADDRESS: "{your-collector}:514",
+// LOG_LOKI_URL: "http://{your-loki}:3100",
+// LOG_ELASTICSEARCH_URL: "http://{your-elasticsearch}:9200",
+func ConfigFromEnvironment() Config {
+	config := DefaultConfig()
+	if timeZone, isTimeZoneSet := os.LookupEnv("LOG_TIMEZONE"); isTimeZoneSet && timeZone != constant.EmptyString {
+		config.TimeZone = timeZone
+	}
+	if directory, isDirectorySet := os.LookupEnv("LOG_DIRECTORY"); isDirectorySet && directory != constant.EmptyString {
+		config.Directory = directory
+	}
+	if fileNameTemplate, isFileNameTemplateSet := os.LookupEnv("LOG_FILE_NAME_TEMPLATE"); isFileNameTemplateSet && fileNameTemplate != constant.EmptyString {
+		config.FileNameTemplate = fileNameTemplate
+	}
+	if serviceName, isServiceNameSet := os.LookupEnv("SERVICE_NAME"); isServiceNameSet && serviceName != constant.EmptyString {
+		config.ServiceName = serviceName
+	}
+	if consoleFormat, isConsoleFormatSet := os.LookupEnv("LOG_CONSOLE_FORMAT"); isConsoleFormatSet {
+		config.ConsoleFormat = consoleFormat
+	}
+	if fileFormat, isFileFormatSet := os.LookupEnv("LOG_FILE_FORMAT"); isFileFormatSet && fileFormat != constant.EmptyString {
+		config.FileFormat = fileFormat
+	}
+	if levelName, isLevelSet := os.LookupEnv("LOG_LEVEL"); isLevelSet && levelName != constant.EmptyString {
+		if level, parseLevelError := ParseLevel(levelName); parseLevelError == nil {
+			config.Level = level
+		}
+	}
+	if samplingFirst, atoiError := strconv.Atoi(os.Getenv("LOG_SAMPLING_FIRST")); atoiError == nil {
+		config.SamplingFirst = samplingFirst
+	}
+	if samplingThereafter, atoiError := strconv.Atoi(os.Getenv("LOG_SAMPLING_THEREAFTER")); atoiError == nil {
+		config.SamplingThereafter = samplingThereafter
+	}
+	if samplingInterval, parseDurationError := time.ParseDuration(os.Getenv("LOG_SAMPLING_INTERVAL")); parseDurationError == nil {
+		config.SamplingInterval = samplingInterval
+	}
+	if redactionName, isRedactionSet := os.LookupEnv("LOG_REDACTION"); isRedactionSet && redactionName != constant.EmptyString {
+		if redaction, parseMaskModeError := ParseMaskMode(redactionName); parseMaskModeError == nil {
+			config.Redaction = redaction
+		}
+	}
+	if memoryRecords, atoiError := strconv.Atoi(os.Getenv("LOG_MEMORY_RECORDS")); atoiError == nil {
+		config.MemoryRecords = memoryRecords
+	}
+	if syslogNetwork, isSyslogNetworkSet := os.LookupEnv("LOG_SYSLOG_NETWORK"); isSyslogNetworkSet && syslogNetwork != constant.EmptyString {
+		config.SyslogNetwork = syslogNetwork
+	}
+	if syslogAddress, isSyslogAddressSet := os.LookupEnv("LOG_SYSLOG_ADDRESS"); isSyslogAddressSet {
+		config.SyslogAddress = syslogAddress
+	}
+	if lokiURL, isLokiURLSet := os.LookupEnv("LOG_LOKI_URL"); isLokiURLSet {
+		config.LokiURL = lokiURL
+	}
+	if elasticsearchURL, isElasticsearchURLSet := os.LookupEnv("LOG_ELASTICSEARCH_URL"); isElasticsearchURLSet {
+		config.ElasticsearchURL = elasticsearchURL
+	}
+	return config
+}
+
+// Location loads the configured time zone.
+// It falls back to UTC when the zone is unknown, e.g. in minimal containers without tzdata.
+func (c Config) Location() *time.Location {
+	if c.TimeZone == constant.EmptyString {
+		return time.UTC
+	}
+	timeZoneLocation, timeLoadLocationErr := time.LoadLocation(c.TimeZone)
+	if timeLoadLocationErr != nil {
+		return time.UTC
+	}
+	return timeZoneLocation
+}
+
+// FileName returns the name of the log file for the day of t.
+func (c Config) FileName(t time.Time) string {
+	return renderFileName(c.FileNameTemplate, c.ServiceName, t)
+}
+
+// renderFileName fills the placeholders of a file name template.
+func renderFileName(template string, serviceName string, t time.Time) string {
+	if template == constant.EmptyString {
+		template = constant.LogFileNameTemplate
+	}
+	return strings.NewReplacer(
+		"{service}", serviceName,
+		"{year}", strconv.Itoa(t.Year()),
+		"{month}", strconv.Itoa(int(t.Month())),
+		"{day}", strconv.Itoa(t.Day()),
+	).Replace(template)
+}
+
+// fileNamePrefix returns the part of the file names that does not depend on the date.
+func fileNamePrefix(template string, serviceName string) string {
+	if template == constant.EmptyString {
+		template = constant.LogFileNameTemplate
+	}
+	prefix := strings.ReplaceAll(template, "{service}", serviceName)
+	if index := strings.Index(prefix, "{"); index >= 0 {
+		prefix = prefix[:index]
+	}
+	return prefix
+}
+
+func executableName() string {
+	name := filepath.Base(os.Args[0])
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == constant.EmptyString || name == "." || name == string(filepath.Separator) {
+		return "service"
+	}
+	return name
+}
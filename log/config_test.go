@@ -0,0 +1,26 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Location(t *testing.T) {
+	if location := (Config{TimeZone: "Not/AZone"}).Location(); location != time.UTC {
+		t.Errorf("Expected UTC for an unknown zone, got %s", location)
+	}
+	if location := (Config{TimeZone: "UTC"}).Location(); location.String() != "UTC" {
+		t.Errorf("Expected UTC, got %s", location)
+	}
+}
+
+func TestConfig_FileName(t *testing.T) {
+	config := Config{FileNameTemplate: "{service}_log_{year}_{month}_{day}.log", ServiceName: "billing"}
+	name := config.FileName(time.Date(2024, time.May, 3, 10, 0, 0, 0, time.UTC))
+	if name != "billing_log_2024_5_3.log" {
+		t.Errorf("Expected %q, got %q", "billing_log_2024_5_3.log", name)
+	}
+	if prefix := fileNamePrefix(config.FileNameTemplate, config.ServiceName); prefix != "billing_log_" {
+		t.Errorf("Expected %q, got %q", "billing_log_", prefix)
+	}
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// fieldsContextKey is the context key of the fields added with With.
+type fieldsContextKey struct{}
+
+// With returns a copy of the context carrying the field, e.g. the route, the client IP or a room ID.
+// Every record logged with the returned context holds the field, in every output.
+// The value keeps its type, so the JSON outputs write numbers and booleans as such.
+// With replaces the value of a key added earlier in the context.
+func With(ctx context.Context, key string, value any) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	previous := ContextFields(ctx)
+	fields := make([]Field, 0, len(previous)+1)
+	for _, field := range previous {
+		if field.Key != key {
+			fields = append(fields, field)
+		}
+	}
+	fields = append(fields, Field{Key: key, Value: value})
+	return context.WithValue(ctx, fieldsContextKey{}, fields)
+}
+
+// ContextFields returns the fields added to the context with With, in the order they were added.
+// The returned slice must not be modified.
+func ContextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsContextKey{}).([]Field)
+	return fields
+}
+
+// traceIdAndUsername reads the constant.TraceIdLogKey and constant.UsernameLogKey values of the context.
+// Values that are not strings are formatted with fmt.Sprint.
+func traceIdAndUsername(ctx context.Context) (traceId string, username string) {
+	return contextString(ctx, constant.TraceIdLogKey), contextString(ctx, constant.UsernameLogKey)
+}
+
+func contextString(ctx context.Context, key any) string {
+	switch value := ctx.Value(key).(type) {
+	case nil:
+		return constant.EmptyString
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
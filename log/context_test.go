@@ -0,0 +1,72 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestWith(t *testing.T) {
+	sink := &recordingSink{}
+	logger := NewLogger(Output{Name: "all", Sink: sink})
+	logger.Redactor = NewRedactor(MaskFull)
+
+	ctx := With(context.Background(), "route", "/rooms/:id")
+	ctx = With(ctx, "roomId", 7)
+	ctx = With(ctx, "password", "secret")
+	child := With(ctx, "roomId", 8)
+	logger.WithLevel(constant.Info, child, "room updated")
+	logger.WithLevel(constant.Info, ctx, "room read")
+
+	if len(sink.records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(sink.records))
+	}
+	fields := sink.records[0].Fields
+	if len(fields) != 3 || fields[0].Key != "route" || fields[1].Key != "password" || fields[2].Key != "roomId" || fields[2].Value != 8 {
+		t.Errorf("Expected the replaced field last with its type, got %+v", fields)
+	}
+	if fields[1].Value == "secret" {
+		t.Errorf("Expected the context fields to be redacted")
+	}
+	if value := ContextFields(ctx)[2].Value; value != "secret" {
+		t.Errorf("Expected the redaction to leave the context untouched, got %v", value)
+	}
+	if sink.records[1].Fields[1].Value != 7 {
+		t.Errorf("Expected the parent context to keep its value, got %+v", sink.records[1].Fields)
+	}
+
+	line, _ := (&JSONFormatter{}).Format(sink.records[0])
+	if !strings.Contains(string(line), `"roomId":8`) {
+		t.Errorf("Expected a JSON number, got %s", line)
+	}
+	if message := sink.records[0].FormattedMessage(); !strings.HasSuffix(message, `room updated route=/rooms/:id password=*** roomId=8`) {
+		t.Errorf("Expected the fields after the text message, got %q", message)
+	}
+}
+
+func TestWith_SlogHandler(t *testing.T) {
+	sink := &recordingSink{}
+	logger := slog.New(NewSlogHandler(NewLogger(Output{Name: "all", Sink: sink}), slog.LevelInfo))
+	ctx := With(context.Background(), "job", "billing")
+	logger.InfoContext(ctx, "job started", "attempt", 2)
+	fields := sink.records[0].Fields
+	if len(fields) != 2 || fields[0].Key != "job" || fields[1].Key != "attempt" {
+		t.Errorf("Expected the context fields before the attributes, got %+v", fields)
+	}
+}
+
+func TestTraceIdAndUsername(t *testing.T) {
+	ctx := context.WithValue(context.Background(), constant.TraceIdLogKey, 42)
+	ctx = context.WithValue(ctx, constant.UsernameLogKey, "user")
+	traceId, username := traceIdAndUsername(ctx)
+	if traceId != "42" || username != "user" {
+		t.Errorf("Expected the values without panicking, got %q %q", traceId, username)
+	}
+	traceId, username = traceIdAndUsername(context.Background())
+	if traceId != constant.EmptyString || username != constant.EmptyString {
+		t.Errorf("Expected empty values, got %q %q", traceId, username)
+	}
+}
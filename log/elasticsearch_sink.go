@@ -0,0 +1,208 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// defaultIndexDateLayout is the date suffix of the daily indices, e.g. "service-2024.05.03"
+const defaultIndexDateLayout = "2006.01.02"
+
+// ElasticsearchSink indexes records with the Elasticsearch _bulk API, one index per day.
+// Every document is the JSONFormatter encoding of the record plus the service and host.
+// Records are batched like splunk.Writer, see BatchOptions. Documents rejected with 429 or a server error are
+// retried, the other rejections and the delivery failures are reported on Errors.
+type ElasticsearchSink struct {
+	// URL is the base URL of the cluster, e.g. http://elasticsearch:9200
+	URL        string
+	HTTPClient *http.Client
+	// IndexPrefix is the index name without its date, the service name by default
+	IndexPrefix string
+	// IndexDateLayout is the time layout of the index suffix, "2006.01.02" by default
+	IndexDateLayout string
+	Location        *time.Location
+	ServiceName     string
+	Hostname        string
+	Username        string
+	Password        string
+	// APIKey is the base64 encoded API key, used instead of the username and password when set
+	APIKey string
+	BatchOptions
+	batcher    batcher
+	httpClient defaultHTTPClient
+}
+
+// elasticsearchDocument is the JSONFormatter line of a record plus the service and host
+type elasticsearchDocument struct {
+	jsonRecord
+	Service string `json:"service,omitempty"`
+	Host    string `json:"host,omitempty"`
+}
+
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// NewElasticsearchSink creates an Elasticsearch sink for the cluster at url.
+// The indices are named after the service of the config and dated in its time zone.
+func NewElasticsearchSink(url string, config Config) *ElasticsearchSink {
+	hostname, _ := os.Hostname()
+	return &ElasticsearchSink{
+		URL:         url,
+		IndexPrefix: config.ServiceName,
+		Location:    config.Location(),
+		ServiceName: config.ServiceName,
+		Hostname:    hostname,
+	}
+}
+
+func (s *ElasticsearchSink) Write(record *Record) error {
+	return s.batcher.add(s.BatchOptions, s.send, record)
+}
+
+// Errors returns the channel of the batches and documents that could not be indexed.
+func (s *ElasticsearchSink) Errors() <-chan error {
+	return s.batcher.errorChannel(s.BatchOptions, s.send)
+}
+
+// Dropped returns the number of records dropped because the buffer was full,
+// or because they were not sent when Close gave up.
+func (s *ElasticsearchSink) Dropped() int64 {
+	return s.batcher.droppedCount()
+}
+
+// Close stops accepting records and sends the buffered ones, until the context ends.
+func (s *ElasticsearchSink) Close(ctx context.Context) error {
+	return s.batcher.close(ctx, s.BatchOptions, s.send)
+}
+
+// Index returns the name of the index holding the record.
+func (s *ElasticsearchSink) Index(record *Record) string {
+	prefix := s.IndexPrefix
+	if prefix == constant.EmptyString {
+		prefix = s.ServiceName
+	}
+	layout := s.IndexDateLayout
+	if layout == constant.EmptyString {
+		layout = defaultIndexDateLayout
+	}
+	location := s.Location
+	if location == nil {
+		location = time.UTC
+	}
+	// index names must be lowercase
+	return strings.ToLower(prefix) + "-" + record.Time.In(location).Format(layout)
+}
+
+func (s *ElasticsearchSink) send(records []*Record) ([]*Record, error) {
+	body, encodeError := s.encode(records)
+	if encodeError != nil {
+		return nil, encodeError
+	}
+	request, newRequestError := http.NewRequest(http.MethodPost, strings.TrimRight(s.URL, "/")+"/_bulk", bytes.NewReader(body))
+	if newRequestError != nil {
+		return nil, newRequestError
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if s.APIKey != constant.EmptyString {
+		request.Header.Set("Authorization", "ApiKey "+s.APIKey)
+	} else if s.Username != constant.EmptyString || s.Password != constant.EmptyString {
+		request.SetBasicAuth(s.Username, s.Password)
+	}
+	response, doError := s.httpClient.get(s.HTTPClient).Do(request)
+	if doError != nil {
+		return records, doError
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		statusError := responseError("elasticsearch", response)
+		if isRetryableStatus(response.StatusCode) {
+			return records, withRetryAfter(statusError, response)
+		}
+		return nil, statusError
+	}
+	var result bulkResponse
+	if decodeError := json.NewDecoder(response.Body).Decode(&result); decodeError != nil {
+		return nil, decodeError
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+	retryRecords, rejectedError := failedBulkItems(records, result.Items)
+	if rejectedError != nil {
+		// retrying would not help the rejected documents, only the throttled ones are sent again
+		s.batcher.report(rejectedError)
+	}
+	if len(retryRecords) > 0 {
+		return retryRecords, fmt.Errorf("elasticsearch could not index %d of %d documents", len(retryRecords), len(records))
+	}
+	return nil, nil
+}
+
+// encode builds the NDJSON body, an index action followed by the document for each record.
+func (s *ElasticsearchSink) encode(records []*Record) ([]byte, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range records {
+		action := map[string]map[string]string{"index": {"_index": s.Index(record)}}
+		if encodeError := encoder.Encode(action); encodeError != nil {
+			return nil, encodeError
+		}
+		document := elasticsearchDocument{
+			jsonRecord: newJSONRecord(record),
+			Service:    s.ServiceName,
+			Host:       s.Hostname,
+		}
+		if encodeError := encoder.Encode(document); encodeError != nil {
+			return nil, encodeError
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// failedBulkItems returns the documents worth retrying and an error describing the rejected ones.
+// The items of a bulk response are in the order of the request.
+func failedBulkItems(records []*Record, items []map[string]bulkResponseItem) ([]*Record, error) {
+	var retryRecords []*Record
+	rejectedCount := 0
+	firstReason := constant.EmptyString
+	for i, item := range items {
+		if i >= len(records) {
+			break
+		}
+		for _, result := range item {
+			if result.Status/100 == 2 {
+				continue
+			}
+			if isRetryableStatus(result.Status) {
+				retryRecords = append(retryRecords, records[i])
+				continue
+			}
+			rejectedCount++
+			if firstReason == constant.EmptyString && result.Error != nil {
+				firstReason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	if rejectedCount == 0 {
+		return retryRecords, nil
+	}
+	return retryRecords, fmt.Errorf("elasticsearch rejected %d of %d documents: %s", rejectedCount, len(records), firstReason)
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestElasticsearchSink(t *testing.T) {
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Expected an NDJSON bulk request, got %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("Authorization") != "ApiKey key" {
+			t.Errorf("Expected the API key, got %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	sink := NewElasticsearchSink(server.URL, Config{ServiceName: "Billing", TimeZone: "Asia/Ho_Chi_Minh"})
+	sink.Hostname = "pod-1"
+	sink.APIKey = "key"
+	sink.FlushThreshold = 2
+	// 20:00 UTC is the next day in Ho Chi Minh City
+	now := time.Date(2024, 5, 3, 20, 0, 0, 0, time.UTC)
+	_ = sink.Write(&Record{Time: now, Level: constant.Info, TraceId: "trace", Message: "first", Fields: []Field{{Key: "roomId", Value: 7}}})
+	_ = sink.Write(&Record{Time: now, Level: constant.Error, Message: "second"})
+
+	var body string
+	select {
+	case body = <-bodies:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the bulk request")
+	}
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected an action and a document per record, got %q", body)
+	}
+	if lines[0] != `{"index":{"_index":"billing-2024.05.04"}}` {
+		t.Errorf("Unexpected action %s", lines[0])
+	}
+	var document map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &document); err != nil {
+		t.Fatalf("Failed to decode the document: %v", err)
+	}
+	if document["message"] != "first" || document["traceId"] != "trace" || document["service"] != "Billing" || document["host"] != "pod-1" {
+		t.Errorf("Unexpected document %v", document)
+	}
+	if fields, _ := document["fields"].(map[string]any); fields["roomId"] != float64(7) {
+		t.Errorf("Expected the typed fields, got %v", document["fields"])
+	}
+}
+
+func TestElasticsearchSink_PartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var messages []string
+		scanner := bufio.NewScanner(r.Body)
+		for i := 0; scanner.Scan(); i++ {
+			if i%2 == 1 {
+				var document map[string]any
+				_ = json.Unmarshal(scanner.Bytes(), &document)
+				messages = append(messages, document["message"].(string))
+			}
+		}
+		mu.Lock()
+		requests = append(requests, messages)
+		attempt := len(requests)
+		mu.Unlock()
+		if attempt == 1 {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"status":201}},
+				{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad field"}}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	sink := NewElasticsearchSink(server.URL, Config{ServiceName: "billing"})
+	sink.FlushThreshold = 3
+	sink.RetryBackoff = time.Millisecond
+	for _, message := range []string{"indexed", "throttled", "malformed"} {
+		_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: message})
+	}
+
+	select {
+	case err := <-sink.Errors():
+		if !strings.Contains(err.Error(), "rejected 1 of 3 documents: mapper_parsing_exception: bad field") {
+			t.Errorf("Expected the malformed document to be reported, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the rejected document")
+	}
+	select {
+	case err := <-sink.Errors():
+		t.Fatalf("Expected the throttled document to be indexed on retry, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("Expected a retry of the throttled document, got %v", requests)
+	}
+	if len(requests[1]) != 1 || requests[1][0] != "throttled" {
+		t.Errorf("Expected only the throttled document to be retried, got %v", requests[1])
+	}
+}
+
+func TestFailedBulkItems(t *testing.T) {
+	records := []*Record{{Message: "a"}, {Message: "b"}}
+	var items []map[string]bulkResponseItem
+	_ = json.Unmarshal([]byte(`[{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad field"}}},{"create":{"status":201}}]`), &items)
+	retryRecords, err := failedBulkItems(records, items)
+	if len(retryRecords) != 0 {
+		t.Errorf("Expected a rejected document not to be retried, got %v", retryRecords)
+	}
+	if err == nil || err.Error() != "elasticsearch rejected 1 of 2 documents: mapper_parsing_exception: bad field" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
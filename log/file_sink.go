@@ -0,0 +1,335 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const (
+	compressedFileSuffix = ".gz"
+	backupTimeFormat     = "20060102T150405.000"
+	megabyte             = 1024 * 1024
+)
+
+// FileSink writes records to a daily log file and keeps the handle open between writes.
+//
+// The file is rotated when the day changes and, if MaxSize is set, when the next write would make it grow past MaxSize.
+// A size rotation renames the current file with a timestamp suffix, e.g. "app_log_2024_5_1-20240501T153000.000.log".
+// Rotated files are compressed and cleaned up in the background according to Compress, MaxBackups and MaxAge.
+// A FileSink is safe for concurrent use, but two sinks must not share the same files.
+type FileSink struct {
+	Formatter Formatter
+	// Directory holding the log files, defaults to constant.LogFileFolder
+	Directory string
+	// FileNameTemplate is the daily file name, defaults to constant.LogFileNameTemplate
+	FileNameTemplate string
+	// ServiceName replaces the {service} placeholder of FileNameTemplate
+	ServiceName string
+	// Location is the time zone deciding the day boundary, time.Local when nil
+	Location *time.Location
+	// MaxSize is the size in bytes at which the file is rotated, 0 disables size rotation
+	MaxSize int64
+	// MaxBackups is the number of rotated files to keep, 0 keeps all of them
+	MaxBackups int
+	// MaxAge is the number of days to keep rotated files, 0 keeps all of them
+	MaxAge int
+	// Compress gzips the rotated files, including the ones a previous process left uncompressed
+	Compress bool
+
+	mu          sync.Mutex
+	file        *os.File
+	currentName string
+	size        int64
+	millLock    sync.Mutex
+	millGroup   sync.WaitGroup
+	// now is time.Now, replaced by the tests crossing a day boundary
+	now func() time.Time
+}
+
+// NewFileSink creates a file sink writing where the config says, a nil formatter falls back to TextFormatter.
+func NewFileSink(config Config, formatter Formatter) *FileSink {
+	if formatter == nil {
+		formatter = &TextFormatter{}
+	}
+	return &FileSink{
+		Formatter:        formatter,
+		Directory:        config.Directory,
+		FileNameTemplate: config.FileNameTemplate,
+		ServiceName:      config.ServiceName,
+		Location:         config.Location(),
+	}
+}
+
+func (s *FileSink) Write(record *Record) error {
+	b, formatError := s.Formatter.Format(record)
+	if formatError != nil {
+		return formatError
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	location := s.Location
+	if location == nil {
+		location = time.Local
+	}
+	now := s.currentTime().In(location)
+	name := s.fileName(now)
+	if s.file == nil || name != s.currentName {
+		if openError := s.openDailyFile(name); openError != nil {
+			return openError
+		}
+	} else if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(b)) > s.MaxSize {
+		if rotateError := s.rotateBySize(now); rotateError != nil {
+			return rotateError
+		}
+	}
+
+	n, writeError := s.file.Write(b)
+	s.size += int64(n)
+	return writeError
+}
+
+// Close closes the current file and waits for the background compression and cleanup.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	closeError := s.closeFile()
+	s.mu.Unlock()
+	s.millGroup.Wait()
+	return closeError
+}
+
+func (s *FileSink) currentTime() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+func (s *FileSink) fileName(t time.Time) string {
+	return renderFileName(s.FileNameTemplate, s.ServiceName, t)
+}
+
+func (s *FileSink) directory() string {
+	if s.Directory == constant.EmptyString {
+		return constant.LogFileFolder
+	}
+	return s.Directory
+}
+
+// openDailyFile switches to the file of the current day, the previous day file is left to the mill.
+func (s *FileSink) openDailyFile(name string) error {
+	if closeError := s.closeFile(); closeError != nil {
+		return closeError
+	}
+	if openError := s.openFile(name); openError != nil {
+		return openError
+	}
+	s.mill()
+	return nil
+}
+
+// rotateBySize renames the current file with a timestamp suffix and reopens a fresh one under the same name.
+func (s *FileSink) rotateBySize(now time.Time) error {
+	name := s.currentName
+	if closeError := s.closeFile(); closeError != nil {
+		return closeError
+	}
+	extension := filepath.Ext(name)
+	currentPath := filepath.Join(s.directory(), name)
+	backupBase := filepath.Join(s.directory(), strings.TrimSuffix(name, extension)+"-"+now.Format(backupTimeFormat))
+	backupPath := backupBase + extension
+	// several rotations may happen within the same millisecond
+	for i := 1; fileExists(backupPath) || fileExists(backupPath+compressedFileSuffix); i++ {
+		backupPath = fmt.Sprintf("%s.%d%s", backupBase, i, extension)
+	}
+	if renameError := os.Rename(currentPath, backupPath); renameError != nil {
+		return renameError
+	}
+	if openError := s.openFile(name); openError != nil {
+		return openError
+	}
+	s.mill()
+	return nil
+}
+
+func (s *FileSink) openFile(name string) error {
+	if makeDirectoryAllError := os.MkdirAll(s.directory(), 0755); makeDirectoryAllError != nil {
+		return makeDirectoryAllError
+	}
+	file, openFileError := os.OpenFile(filepath.Join(s.directory(), name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openFileError != nil {
+		return openFileError
+	}
+	info, statError := file.Stat()
+	if statError != nil {
+		_ = file.Close()
+		return statError
+	}
+	s.file = file
+	s.currentName = name
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// mill compresses every rotated file left uncompressed, the days before and the size rotations alike,
+// and removes the files exceeding the retention in the background.
+func (s *FileSink) mill() {
+	s.millGroup.Add(1)
+	go func() {
+		defer s.millGroup.Done()
+		s.millLock.Lock()
+		defer s.millLock.Unlock()
+		if s.Compress {
+			if compressError := s.compressRotatedFiles(); compressError != nil {
+				fmt.Printf("An error has been occurred when compressing log files: %v\n", compressError)
+			}
+		}
+		if cleanupError := s.removeExpiredFiles(); cleanupError != nil {
+			fmt.Printf("An error has been occurred when cleaning up log files: %v\n", cleanupError)
+		}
+	}()
+}
+
+// rotatedFiles lists the files of the sink other than the active one, compressed or not.
+func (s *FileSink) rotatedFiles() ([]os.DirEntry, error) {
+	prefix := fileNamePrefix(s.FileNameTemplate, s.ServiceName)
+	extension := filepath.Ext(s.fileName(time.Now()))
+
+	entries, readDirError := os.ReadDir(s.directory())
+	if readDirError != nil {
+		return nil, readDirError
+	}
+	// read after the listing: a file listed but no longer active was closed for good, as the days only move forward
+	s.mu.Lock()
+	activeName := s.currentName
+	s.mu.Unlock()
+	var rotated []os.DirEntry
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == activeName || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, extension) && !strings.HasSuffix(name, extension+compressedFileSuffix) {
+			continue
+		}
+		rotated = append(rotated, entry)
+	}
+	return rotated, nil
+}
+
+// compressRotatedFiles gzips the rotated files that are not compressed yet.
+func (s *FileSink) compressRotatedFiles() error {
+	rotated, listError := s.rotatedFiles()
+	if listError != nil {
+		return listError
+	}
+	for _, entry := range rotated {
+		if strings.HasSuffix(entry.Name(), compressedFileSuffix) {
+			continue
+		}
+		path := filepath.Join(s.directory(), entry.Name())
+		if compressError := compressFile(path); compressError != nil {
+			return fmt.Errorf("%s: %w", path, compressError)
+		}
+	}
+	return nil
+}
+
+// removeExpiredFiles deletes the rotated files beyond MaxBackups or older than MaxAge days.
+func (s *FileSink) removeExpiredFiles() error {
+	if s.MaxBackups <= 0 && s.MaxAge <= 0 {
+		return nil
+	}
+	rotated, listError := s.rotatedFiles()
+	if listError != nil {
+		return listError
+	}
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backup
+	for _, entry := range rotated {
+		name := entry.Name()
+		info, infoError := entry.Info()
+		if infoError != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(s.directory(), name), modTime: info.ModTime()})
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	cutoff := time.Now().AddDate(0, 0, -s.MaxAge)
+	for i, b := range backups {
+		expired := (s.MaxBackups > 0 && i >= s.MaxBackups) || (s.MaxAge > 0 && b.modTime.Before(cutoff))
+		if !expired {
+			continue
+		}
+		if removeError := os.Remove(b.path); removeError != nil && !os.IsNotExist(removeError) {
+			return removeError
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, statError := os.Stat(path)
+	return statError == nil
+}
+
+// compressFile gzips the file next to itself and removes the original.
+// The compressed file keeps the modification time of the original, which the retention is based on.
+func compressFile(path string) error {
+	source, openError := os.Open(path)
+	if openError != nil {
+		return openError
+	}
+	defer func(source *os.File) {
+		_ = source.Close()
+	}(source)
+
+	destination, createError := os.OpenFile(path+compressedFileSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if createError != nil {
+		return createError
+	}
+	gzipWriter := gzip.NewWriter(destination)
+	if _, copyError := io.Copy(gzipWriter, source); copyError != nil {
+		_ = destination.Close()
+		_ = os.Remove(path + compressedFileSuffix)
+		return copyError
+	}
+	if closeError := gzipWriter.Close(); closeError != nil {
+		_ = destination.Close()
+		return closeError
+	}
+	if closeError := destination.Close(); closeError != nil {
+		return closeError
+	}
+	if info, statError := source.Stat(); statError == nil {
+		_ = os.Chtimes(path+compressedFileSuffix, info.ModTime(), info.ModTime())
+	}
+	_ = source.Close()
+	return os.Remove(path)
+}
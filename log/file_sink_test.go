@@ -0,0 +1,146 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestFileSink_RotateBySize(t *testing.T) {
+	directory := t.TempDir()
+	sink := NewFileSink(Config{Directory: directory, FileNameTemplate: "{service}_log_{year}_{month}_{day}.log", ServiceName: "test"}, nil)
+	sink.MaxSize = 200
+	sink.MaxBackups = 2
+	sink.Compress = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "a log line long enough to fill the file"}); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var active, compressed int
+	for _, entry := range entries {
+		switch {
+		case strings.HasSuffix(entry.Name(), ".log.gz"):
+			compressed++
+		case strings.HasSuffix(entry.Name(), ".log"):
+			active++
+			info, _ := entry.Info()
+			if info.Size() > sink.MaxSize {
+				t.Errorf("Expected %s to be smaller than %d bytes, got %d", entry.Name(), sink.MaxSize, info.Size())
+			}
+		}
+	}
+	if active != 1 {
+		t.Errorf("Expected 1 active file, got %d", active)
+	}
+	if compressed != sink.MaxBackups {
+		t.Errorf("Expected %d compressed backups, got %d", sink.MaxBackups, compressed)
+	}
+}
+
+func TestFileSink_RemoveExpiredFiles(t *testing.T) {
+	directory := t.TempDir()
+	sink := NewFileSink(Config{Directory: directory, FileNameTemplate: "{service}_log_{year}_{month}_{day}.log", ServiceName: "test"}, nil)
+	sink.MaxAge = 7
+
+	old := filepath.Join(directory, "test_log_2020_1_1.log.gz")
+	recent := filepath.Join(directory, "test_log_2020_1_2.log.gz")
+	unrelated := filepath.Join(directory, "other_2020_1_1.log")
+	for _, path := range []string{old, recent, unrelated} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	tenDaysAgo := time.Now().AddDate(0, 0, -10)
+	_ = os.Chtimes(old, tenDaysAgo, tenDaysAgo)
+	_ = os.Chtimes(unrelated, tenDaysAgo, tenDaysAgo)
+
+	if err := sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "message"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_ = sink.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", old)
+	}
+	for _, path := range []string{recent, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %s", path, err)
+		}
+	}
+}
+
+func TestFileSink_RotateByDay(t *testing.T) {
+	directory := t.TempDir()
+	config := Config{Directory: directory, FileNameTemplate: "{service}_log_{year}_{month}_{day}.log", ServiceName: "test"}
+	now := time.Date(2024, 5, 3, 23, 59, 59, 0, time.UTC)
+	newSink := func() *FileSink {
+		sink := NewFileSink(config, nil)
+		sink.Location = time.UTC
+		sink.Compress = true
+		sink.now = func() time.Time {
+			return now
+		}
+		return sink
+	}
+	write := func(sink *FileSink, message string) {
+		if err := sink.Write(&Record{Time: now, Level: constant.Info, Message: message}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	expectFiles := func(expected ...string) {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected the files %q, got %q", expected, names)
+		}
+	}
+
+	sink := newSink()
+	write(sink, "before midnight")
+	now = now.Add(2 * time.Second)
+	write(sink, "after midnight")
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expectFiles("test_log_2024_5_3.log.gz", "test_log_2024_5_4.log")
+	b, _ := os.ReadFile(filepath.Join(directory, "test_log_2024_5_4.log"))
+	if !strings.Contains(string(b), "after midnight") || strings.Contains(string(b), "before midnight") {
+		t.Errorf("Expected only the record of the new day in its file, got %q", b)
+	}
+
+	// a process restarted the next day compresses the file of the previous one
+	now = now.AddDate(0, 0, 1)
+	restarted := newSink()
+	write(restarted, "next day")
+	if err := restarted.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expectFiles("test_log_2024_5_3.log.gz", "test_log_2024_5_4.log.gz", "test_log_2024_5_5.log")
+}
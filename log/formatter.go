@@ -0,0 +1,140 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const (
+	TextFormat   = "text"
+	JSONFormat   = "json"
+	LogfmtFormat = "logfmt"
+)
+
+// Formatter encodes a record into the bytes written by a sink.
+type Formatter interface {
+	Format(record *Record) ([]byte, error)
+}
+
+// NewFormatter returns the formatter registered under the given name: "text", "json" or "logfmt".
+func NewFormatter(name string) (Formatter, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case TextFormat, constant.EmptyString:
+		return &TextFormatter{}, nil
+	case JSONFormat:
+		return &JSONFormatter{}, nil
+	case LogfmtFormat:
+		return &LogfmtFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", name)
+	}
+}
+
+// TextFormatter writes the human readable line used by the service_log files:
+// "2006-01-02 15:04:05: INFO - [traceId] [username] 👉️ 	message"
+type TextFormatter struct{}
+
+func (f *TextFormatter) Format(record *Record) ([]byte, error) {
+	return []byte(fmt.Sprintf(
+		"%s: %s - %s\n",
+		record.Time.Format(constant.YyyyMmDdHhMmSsFormat),
+		string(record.Level),
+		record.FormattedMessage(),
+	)), nil
+}
+
+// JSONFormatter writes one JSON object per line:
+// {"timestamp":"...","level":"INFO","traceId":"...","username":"...","message":"...","caller":"...","stack":"...","fields":{...}}
+type JSONFormatter struct{}
+
+type jsonRecord struct {
+	Timestamp string         `json:"timestamp"`
+	Level     string         `json:"level"`
+	TraceId   string         `json:"traceId"`
+	Username  string         `json:"username"`
+	Message   string         `json:"message"`
+	Caller    string         `json:"caller,omitempty"`
+	Stack     string         `json:"stack,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+func (f *JSONFormatter) Format(record *Record) ([]byte, error) {
+	b, marshalError := json.Marshal(newJSONRecord(record))
+	if marshalError != nil {
+		return nil, marshalError
+	}
+	return append(b, '\n'), nil
+}
+
+func newJSONRecord(record *Record) jsonRecord {
+	line := jsonRecord{
+		Timestamp: record.Time.Format(time.RFC3339Nano),
+		Level:     string(record.Level),
+		TraceId:   record.TraceId,
+		Username:  record.Username,
+		Message:   record.Message,
+		Caller:    record.Caller.String(),
+		Stack:     record.Stack,
+	}
+	if len(record.Fields) > 0 {
+		line.Fields = make(map[string]any, len(record.Fields))
+		for _, field := range record.Fields {
+			line.Fields[field.Key] = jsonValue(field.Value)
+		}
+	}
+	return line
+}
+
+// jsonValue keeps errors readable, json.Marshal would encode them as an empty object.
+func jsonValue(value any) any {
+	if err, isError := value.(error); isError {
+		return err.Error()
+	}
+	return value
+}
+
+// LogfmtFormatter writes one logfmt line per record:
+// ts=... level=INFO traceId=... username=... msg="..." caller=... stack="..." key=value
+type LogfmtFormatter struct{}
+
+func (f *LogfmtFormatter) Format(record *Record) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := logfmt.NewEncoder(&buf)
+	keyvals := []any{
+		"ts", record.Time.Format(time.RFC3339Nano),
+		"level", string(record.Level),
+		"traceId", record.TraceId,
+		"username", record.Username,
+		"msg", record.Message,
+	}
+	if caller := record.Caller.String(); caller != constant.EmptyString {
+		keyvals = append(keyvals, "caller", caller)
+	}
+	if record.Stack != constant.EmptyString {
+		keyvals = append(keyvals, "stack", record.Stack)
+	}
+	for _, field := range record.Fields {
+		keyvals = append(keyvals, field.Key, field.Value)
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		encodeError := encoder.EncodeKeyval(keyvals[i], keyvals[i+1])
+		if errors.Is(encodeError, logfmt.ErrUnsupportedValueType) {
+			// maps, slices and structs are written with their default format
+			encodeError = encoder.EncodeKeyval(keyvals[i], fmt.Sprint(keyvals[i+1]))
+		}
+		if encodeError != nil {
+			return nil, encodeError
+		}
+	}
+	if endRecordError := encoder.EndRecord(); endRecordError != nil {
+		return nil, endRecordError
+	}
+	return buf.Bytes(), nil
+}
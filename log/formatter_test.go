@@ -0,0 +1,63 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func testRecord() *Record {
+	return &Record{
+		Time:     time.Date(2024, time.May, 3, 10, 20, 30, 0, time.UTC),
+		Level:    constant.Warn,
+		TraceId:  "trace",
+		Username: "user",
+		Message:  "first line\nsecond line",
+		Fields: []Field{
+			{Key: "roomId", Value: 42},
+			{Key: "paid", Value: true},
+			{Key: "cause", Value: errors.New("boom")},
+		},
+	}
+}
+
+func TestJSONFormatter_Format(t *testing.T) {
+	b, err := (&JSONFormatter{}).Format(testRecord())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %s", b, err)
+	}
+	fields := decoded["fields"].(map[string]any)
+	if decoded["level"] != "WARN" || decoded["traceId"] != "trace" || decoded["message"] != "first line\nsecond line" {
+		t.Errorf("Unexpected record %v", decoded)
+	}
+	if fields["roomId"] != float64(42) || fields["paid"] != true || fields["cause"] != "boom" {
+		t.Errorf("Expected typed fields, got %v", fields)
+	}
+}
+
+func TestLogfmtFormatter_Format(t *testing.T) {
+	b, err := (&LogfmtFormatter{}).Format(testRecord())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expect := `ts=2024-05-03T10:20:30Z level=WARN traceId=trace username=user msg="first line\nsecond line" roomId=42 paid=true cause=boom` + "\n"
+	if string(b) != expect {
+		t.Errorf("Expected %q, got %q", expect, b)
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	if _, err := NewFormatter("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+	if formatter, err := NewFormatter("JSON"); err != nil || formatter == nil {
+		t.Errorf("Expected the JSON formatter, got %v", err)
+	}
+}
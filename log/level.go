@@ -0,0 +1,47 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// Levels from the least to the most severe.
+var levels = []constant.LogLevelType{
+	constant.Trace,
+	constant.Debug,
+	constant.Info,
+	constant.Warn,
+	constant.Error,
+	constant.Fatal,
+}
+
+// ParseLevel returns the level matching the name, ignoring case.
+func ParseLevel(name string) (constant.LogLevelType, error) {
+	for _, level := range levels {
+		if strings.EqualFold(string(level), strings.TrimSpace(name)) {
+			return level, nil
+		}
+	}
+	return constant.EmptyString, fmt.Errorf("unknown log level %q", name)
+}
+
+// LevelEnabled reports whether a record with the given level passes the minimum level.
+// An empty minimum accepts every level.
+func LevelEnabled(minimum constant.LogLevelType, level constant.LogLevelType) bool {
+	if minimum == constant.EmptyString {
+		return true
+	}
+	return levelSeverity(level) >= levelSeverity(minimum)
+}
+
+// levelSeverity orders the levels, an unknown level is treated as INFO.
+func levelSeverity(level constant.LogLevelType) int {
+	for severity, known := range levels {
+		if known == level {
+			return severity
+		}
+	}
+	return levelSeverity(constant.Info)
+}
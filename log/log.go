@@ -4,102 +4,21 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
-	"github.com/charmbracelet/log"
 	"github.com/tuanloc1105/go-common-lib/constant"
-	"github.com/tuanloc1105/go-common-lib/utils/splunk/v2"
 )
 
+// WithLevel writes content through the default Logger, see Default and SetDefault.
 func WithLevel(level constant.LogLevelType, ctx context.Context, content string) {
+	Default().log(2, level, ctx, content)
+}
 
-	// ensure that ctx is never nil
-	if ctx == nil {
-		ctx = context.Background()
-		ctx = context.WithValue(ctx, constant.UsernameLogKey, "nil ctx input")
-		ctx = context.WithValue(ctx, constant.TraceIdLogKey, "nil ctx input")
-	}
-
-	timeZoneLocation, timeLoadLocationErr := time.LoadLocation("Asia/Ho_Chi_Minh")
-	if timeLoadLocationErr != nil {
-		return
-	}
-	currentTimestamp := time.Now().In(timeZoneLocation)
-	usernameFromContext := ctx.Value(constant.UsernameLogKey)
-	traceIdFromContext := ctx.Value(constant.TraceIdLogKey)
-	username := constant.EmptyString
-	traceId := constant.EmptyString
-	if usernameFromContext != nil {
-		username = usernameFromContext.(string)
-	}
-	if traceIdFromContext != nil {
-		traceId = traceIdFromContext.(string)
-	}
-	// fmt.Println(strings.Compare(string(level), string(constant.LogLevelType("INFO"))))
-	var message = fmt.Sprintf(
-		constant.LogPattern,
-		traceId,
-		username,
-		content,
-	)
-	switch level {
-	case constant.Info:
-		log.Info(
-			message,
-		)
-	case constant.Warn:
-		log.Warn(
-			message,
-		)
-	case constant.Error:
-		log.Error(
-			message,
-		)
-	case constant.Debug:
-		log.Debug(
-			message,
-		)
-	default:
-		log.Info(
-			message,
-		)
-	}
-
-	host, token, source, sourcetype, index, splunkInfoIsFullSetInEnv := GetSplunkInformationFromEnvironment()
-
-	if splunkInfoIsFullSetInEnv {
-		splunkClient := splunk.NewClient(
-			nil,
-			host,
-			token,
-			source,
-			sourcetype,
-			index,
-		)
-		err := splunkClient.Log(
-			message,
-		)
-		if err != nil {
-			log.Error(err)
-		}
-	}
-
-	appendLogToFileError := AppendLogToFile(
-		fmt.Sprintf(
-			"%s: %s - %s\n",
-			currentTimestamp.Format(constant.YyyyMmDdHhMmSsFormat),
-			string(level),
-			message,
-		),
-	)
-	if appendLogToFileError != nil {
-		log.Error(fmt.Sprintf(
-			constant.LogPattern,
-			traceId,
-			username,
-			fmt.Sprintf("An error has been occurred when appending log to file: %s", appendLogToFileError.Error()),
-		))
-	}
+// WithStack writes content with the stack trace of the caller through the default Logger.
+// Called from a deferred recover, the stack and the caller are the ones of the panic.
+func WithStack(level constant.LogLevelType, ctx context.Context, content string) {
+	Default().logWithStack(2, level, ctx, content)
 }
 
 // GetSplunkInformationFromEnvironment
@@ -120,18 +39,37 @@ func GetSplunkInformationFromEnvironment() (host string, token string, source st
 	return splunkHost, splunkToken, splunkSource, splunkSourcetype, splunkIndex, true
 }
 
-func AppendLogToFile(log string) error {
-	timeZoneLocation, timeLoadLocationErr := time.LoadLocation("Asia/Ho_Chi_Minh")
-	if timeLoadLocationErr != nil {
-		return timeLoadLocationErr
+// GetSplunkRoutesFromEnvironment reads the per level index, source type and source of the Splunk output.
+// SPLUNK_INDEX_ERROR: "{your-error-index}",
+// SPLUNK_SOURCETYPE_ERROR: "{your-error-sourcetype}",
+// SPLUNK_SOURCE_ERROR: "{your-error-source}",
+// and likewise for TRACE, DEBUG, INFO, WARN and FATAL.
+func GetSplunkRoutesFromEnvironment() map[constant.LogLevelType]SplunkRoute {
+	routes := make(map[constant.LogLevelType]SplunkRoute)
+	for _, level := range levels {
+		route := SplunkRoute{
+			Index:      os.Getenv("SPLUNK_INDEX_" + string(level)),
+			SourceType: os.Getenv("SPLUNK_SOURCETYPE_" + string(level)),
+			Source:     os.Getenv("SPLUNK_SOURCE_" + string(level)),
+		}
+		if route != (SplunkRoute{}) {
+			routes[level] = route
+		}
 	}
-	currentTimestamp := time.Now().In(timeZoneLocation)
+	return routes
+}
+
+// AppendLogToFile appends a line to the daily log file described by ConfigFromEnvironment.
+// It opens and closes the file on every call, prefer a FileSink for regular logging.
+func AppendLogToFile(log string) error {
+	config := ConfigFromEnvironment()
+	currentTimestamp := time.Now().In(config.Location())
 
-	logFileName := fmt.Sprintf(constant.LogFileLocation, currentTimestamp.Year(), int(currentTimestamp.Month()), currentTimestamp.Day())
+	logFileName := config.FileName(currentTimestamp)
 
 	// check if log folder is existed or not
-	if _, directoryStatusError := os.Stat(constant.LogFileFolder); os.IsNotExist(directoryStatusError) {
-		makeDirectoryAllError := os.MkdirAll(constant.LogFileFolder, 0755)
+	if _, directoryStatusError := os.Stat(config.Directory); os.IsNotExist(directoryStatusError) {
+		makeDirectoryAllError := os.MkdirAll(config.Directory, 0755)
 		if makeDirectoryAllError != nil {
 			return makeDirectoryAllError
 		}
@@ -160,7 +98,7 @@ func AppendLogToFile(log string) error {
 	// O_RDWR: It opens the file read-write.
 	// O_APPEND: It appends data to the file when writing.
 	// O_CREATE: It creates a new file if none exists.
-	file, openFileError := os.OpenFile(constant.LogFileFolder+logFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	file, openFileError := os.OpenFile(filepath.Join(config.Directory, logFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 
 	if openFileError != nil {
 		return openFileError
@@ -0,0 +1,479 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-logfmt/logfmt"
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/utils/splunk/v2"
+)
+
+const (
+	ConsoleOutputName       = "console"
+	FileOutputName          = "file"
+	SplunkOutputName        = "splunk"
+	MemoryOutputName        = "memory"
+	SyslogOutputName        = "syslog"
+	LokiOutputName          = "loki"
+	ElasticsearchOutputName = "elasticsearch"
+
+	defaultMaxFileSize = 100 * megabyte
+	defaultMaxFileAge  = 30
+)
+
+// Record is a single log line handed to every sink of a Logger.
+type Record struct {
+	Time     time.Time             `json:"time"`
+	Level    constant.LogLevelType `json:"level"`
+	TraceId  string                `json:"traceId"`
+	Username string                `json:"username"`
+	Message  string                `json:"message"`
+	Fields   []Field               `json:"fields,omitempty"`
+	Caller   Caller                `json:"caller"`
+	// Stack is the formatted stack trace, only set by WithStack
+	Stack string `json:"stack,omitempty"`
+}
+
+// Field is an extra key/value pair attached to a record.
+// The value keeps its type so structured outputs can encode numbers and booleans as such.
+type Field struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// FormattedMessage returns the message in the constant.LogPattern layout, followed by the fields as
+// logfmt key=value pairs and the stack trace if any.
+func (r *Record) FormattedMessage() string {
+	message := fmt.Sprintf(
+		constant.LogPattern,
+		r.TraceId,
+		r.Username,
+		r.Message,
+	)
+	if len(r.Fields) > 0 {
+		message += " " + r.fieldsText()
+	}
+	if r.Stack != constant.EmptyString {
+		message += "\n" + r.Stack
+	}
+	return message
+}
+
+// fieldsText encodes the fields as logfmt, the values logfmt does not support are written with fmt.Sprint.
+func (r *Record) fieldsText() string {
+	var buf bytes.Buffer
+	encoder := logfmt.NewEncoder(&buf)
+	for _, field := range r.Fields {
+		encodeError := encoder.EncodeKeyval(field.Key, field.Value)
+		if encodeError != nil {
+			_ = encoder.EncodeKeyval(field.Key, fmt.Sprint(field.Value))
+		}
+	}
+	return buf.String()
+}
+
+// Sink receives the records of a Logger. Implement it to send log lines to a custom output.
+type Sink interface {
+	Write(record *Record) error
+}
+
+// Output binds a Sink to a Logger under a name and with a minimum level.
+// An empty Level lets every record through. The level can be changed at runtime with Logger.SetOutputLevel.
+type Output struct {
+	Name  string
+	Level constant.LogLevelType
+	Sink  Sink
+}
+
+// Logger dispatches records to a list of outputs.
+// A Logger should be built once at startup with NewLogger and shared.
+//
+// Each output has its own minimum level. A package level, set with SetPackageLevel,
+// replaces the minimum level of every output for the records logged from that package and its sub packages.
+type Logger struct {
+	// Location is the time zone of the record timestamps, time.Local when nil
+	Location *time.Location
+	// ErrorHandler is called when a sink fails to write a record, defaults to printing on the console
+	ErrorHandler func(output string, record *Record, err error)
+	// Redactor masks sensitive data in every record before it reaches the outputs, nil disables the redaction
+	Redactor      *Redactor
+	mu            sync.RWMutex
+	outputs       []Output
+	packageLevels map[string]constant.LogLevelType
+	sampler       atomic.Pointer[Sampler]
+	closed        atomic.Bool
+}
+
+// LevelSettings is a snapshot of the levels of a Logger.
+type LevelSettings struct {
+	Outputs  map[string]constant.LogLevelType `json:"outputs"`
+	Packages map[string]constant.LogLevelType `json:"packages"`
+}
+
+// NewLogger creates a Logger writing to the given outputs.
+func NewLogger(outputs ...Output) *Logger {
+	return &Logger{
+		ErrorHandler: printOutputError,
+		outputs:      outputs,
+	}
+}
+
+// AddOutput registers a new output on the logger.
+func (l *Logger) AddOutput(output Output) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.outputs = append(l.outputs, output)
+}
+
+// RemoveOutput unregisters the output with the given name and reports whether it existed.
+func (l *Logger) RemoveOutput(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, output := range l.outputs {
+		if output.Name == name {
+			l.outputs = append(l.outputs[:i:i], l.outputs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Outputs returns a copy of the registered outputs.
+func (l *Logger) Outputs() []Output {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Output(nil), l.outputs...)
+}
+
+// SetOutputLevel changes the minimum level of the named output, an empty level lets every record through.
+func (l *Logger) SetOutputLevel(name string, level constant.LogLevelType) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, output := range l.outputs {
+		if output.Name == name {
+			// copy on write, Write iterates over the previous slice without holding the lock
+			outputs := append([]Output(nil), l.outputs...)
+			outputs[i].Level = level
+			l.outputs = outputs
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log output %q", name)
+}
+
+// SetPackageLevel sets the minimum level of the records logged from a package, e.g. "github.com/org/app/repository".
+// An empty level removes the package level.
+func (l *Logger) SetPackageLevel(packagePath string, level constant.LogLevelType) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	packageLevels := make(map[string]constant.LogLevelType, len(l.packageLevels)+1)
+	for path, packageLevel := range l.packageLevels {
+		packageLevels[path] = packageLevel
+	}
+	if level == constant.EmptyString {
+		delete(packageLevels, packagePath)
+	} else {
+		packageLevels[packagePath] = level
+	}
+	l.packageLevels = packageLevels
+}
+
+// Levels returns the current output and package levels.
+func (l *Logger) Levels() LevelSettings {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	settings := LevelSettings{
+		Outputs:  make(map[string]constant.LogLevelType, len(l.outputs)),
+		Packages: make(map[string]constant.LogLevelType, len(l.packageLevels)),
+	}
+	for _, output := range l.outputs {
+		settings.Outputs[output.Name] = output.Level
+	}
+	for path, level := range l.packageLevels {
+		settings.Packages[path] = level
+	}
+	return settings
+}
+
+// WithLevel builds a record from the context and content and writes it to every output accepting the level.
+func (l *Logger) WithLevel(level constant.LogLevelType, ctx context.Context, content string) {
+	l.log(2, level, ctx, content)
+}
+
+// WithStack is WithLevel with the stack trace of the caller attached to the record.
+// Called from a deferred recover, the stack and the caller are the ones of the panic.
+func (l *Logger) WithStack(level constant.LogLevelType, ctx context.Context, content string) {
+	l.logWithStack(2, level, ctx, content)
+}
+
+func (l *Logger) logWithStack(skip int, level constant.LogLevelType, ctx context.Context, content string) {
+	record := l.newRecord(level, ctx, content)
+	record.Stack, record.Caller = captureStack(skip)
+	l.Write(record)
+}
+
+// log builds the record of WithLevel, skip is the number of frames between the caller and log.
+func (l *Logger) log(skip int, level constant.LogLevelType, ctx context.Context, content string) {
+	record := l.newRecord(level, ctx, content)
+	record.Caller = callerAt(skip)
+	l.Write(record)
+}
+
+func (l *Logger) newRecord(level constant.LogLevelType, ctx context.Context, content string) *Record {
+
+	// ensure that ctx is never nil
+	if ctx == nil {
+		ctx = context.Background()
+		ctx = context.WithValue(ctx, constant.UsernameLogKey, "nil ctx input")
+		ctx = context.WithValue(ctx, constant.TraceIdLogKey, "nil ctx input")
+	}
+
+	traceId, username := traceIdAndUsername(ctx)
+	return &Record{
+		Time:     time.Now().In(l.location()),
+		Level:    level,
+		TraceId:  traceId,
+		Username: username,
+		Message:  content,
+		// copied, the redaction masks the values of the record in place
+		Fields: append([]Field(nil), ContextFields(ctx)...),
+	}
+}
+
+func (l *Logger) location() *time.Location {
+	if l.Location == nil {
+		return time.Local
+	}
+	return l.Location
+}
+
+// SetSampler enables sampling with the given sampler and writes its summaries every interval,
+// until the sampler is stopped. A nil sampler disables sampling.
+func (l *Logger) SetSampler(sampler *Sampler) {
+	if previous := l.sampler.Swap(sampler); previous != nil {
+		previous.Stop()
+	}
+	if sampler != nil {
+		go l.writeSamplingSummaries(sampler)
+	}
+}
+
+func (l *Logger) writeSamplingSummaries(sampler *Sampler) {
+	sampler.init()
+	defer close(sampler.stopped)
+	ticker := time.NewTicker(sampler.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, summary := range sampler.Summaries(l.location()) {
+				l.dispatch(summary)
+			}
+		case <-sampler.stop:
+			for _, summary := range sampler.Summaries(l.location()) {
+				l.dispatch(summary)
+			}
+			return
+		}
+	}
+}
+
+// Write hands an already built record to every output accepting its level, unless the sampler drops it.
+// Records are discarded once the logger is shut down.
+func (l *Logger) Write(record *Record) {
+	if l.closed.Load() {
+		return
+	}
+	if l.Redactor != nil {
+		l.Redactor.RedactRecord(record)
+	}
+	if sampler := l.sampler.Load(); sampler != nil && !sampler.Allow(record) {
+		return
+	}
+	l.dispatch(record)
+}
+
+func (l *Logger) dispatch(record *Record) {
+	l.mu.RLock()
+	outputs := l.outputs
+	packageLevels := l.packageLevels
+	l.mu.RUnlock()
+	packageLevel := constant.LogLevelType(constant.EmptyString)
+	if len(packageLevels) > 0 {
+		packageLevel = lookupPackageLevel(packageLevels, record.Caller.Package())
+	}
+	for _, output := range outputs {
+		minimum := output.Level
+		if packageLevel != constant.EmptyString {
+			minimum = packageLevel
+		}
+		if !LevelEnabled(minimum, record.Level) {
+			continue
+		}
+		if err := output.Sink.Write(record); err != nil && l.ErrorHandler != nil {
+			l.ErrorHandler(output.Name, record, err)
+		}
+	}
+}
+
+// reportErrors forwards the asynchronous delivery errors of an output to the ErrorHandler.
+func (l *Logger) reportErrors(output string, errors <-chan error) {
+	for err := range errors {
+		if l.ErrorHandler != nil {
+			l.ErrorHandler(output, &Record{}, err)
+		}
+	}
+}
+
+// lookupPackageLevel returns the level of the closest configured parent of the package.
+func lookupPackageLevel(packageLevels map[string]constant.LogLevelType, packagePath string) constant.LogLevelType {
+	for path := packagePath; path != constant.EmptyString; {
+		if level, isLevelSet := packageLevels[path]; isLevelSet {
+			return level
+		}
+		lastSlash := strings.LastIndex(path, "/")
+		if lastSlash < 0 {
+			break
+		}
+		path = path[:lastSlash]
+	}
+	return constant.EmptyString
+}
+
+func printOutputError(output string, record *Record, err error) {
+	log.Error(fmt.Sprintf(
+		constant.LogPattern,
+		record.TraceId,
+		record.Username,
+		fmt.Sprintf("An error has been occurred when writing log to %s: %s", output, err.Error()),
+	))
+}
+
+var (
+	defaultLogger     atomic.Pointer[Logger]
+	defaultLoggerOnce sync.Once
+)
+
+// Default returns the logger used by WithLevel.
+// Unless replaced with SetDefault, it is built on first use by NewDefaultLogger.
+func Default() *Logger {
+	defaultLoggerOnce.Do(func() {
+		if defaultLogger.Load() == nil {
+			defaultLogger.CompareAndSwap(nil, NewDefaultLogger())
+		}
+	})
+	return defaultLogger.Load()
+}
+
+// MemorySink returns the RingBufferSink of the "memory" output of the logger, nil if there is none.
+func (l *Logger) MemorySink() *RingBufferSink {
+	for _, output := range l.Outputs() {
+		if ringBufferSink, isRingBufferSink := output.Sink.(*RingBufferSink); isRingBufferSink && output.Name == MemoryOutputName {
+			return ringBufferSink
+		}
+	}
+	return nil
+}
+
+// SetDefault replaces the logger used by WithLevel.
+func SetDefault(logger *Logger) {
+	defaultLogger.Store(logger)
+}
+
+// NewDefaultLogger creates the default logger from ConfigFromEnvironment, see NewDefaultLoggerWithConfig.
+func NewDefaultLogger() *Logger {
+	return NewDefaultLoggerWithConfig(ConfigFromEnvironment())
+}
+
+// NewDefaultLoggerWithConfig creates a Logger with a console and a file output, plus a Splunk output
+// when the SPLUNK_* environment variables are set.
+// The log files are rotated at 100 MB, compressed, and removed after 30 days.
+// Sensitive data is masked unless the redaction of the config is MaskNone.
+// The last records are kept in memory, see RingBufferSink and MemorySink.
+// Syslog, Loki and Elasticsearch outputs are added when their address is set in the config.
+// An unknown format in the config falls back to the text format.
+func NewDefaultLoggerWithConfig(config Config) *Logger {
+	var consoleFormatter Formatter
+	if config.ConsoleFormat != constant.EmptyString {
+		consoleFormatter = formatterOrText(config.ConsoleFormat)
+	}
+	fileSink := NewFileSink(config, formatterOrText(config.FileFormat))
+	fileSink.MaxSize = defaultMaxFileSize
+	fileSink.MaxAge = defaultMaxFileAge
+	fileSink.Compress = true
+	consoleLevel := config.Level
+	if consoleLevel == constant.EmptyString {
+		consoleLevel = constant.Info
+	}
+	logger := NewLogger(
+		Output{Name: ConsoleOutputName, Level: consoleLevel, Sink: NewConsoleSink(consoleFormatter)},
+		Output{Name: FileOutputName, Level: config.Level, Sink: fileSink},
+	)
+	logger.Location = config.Location()
+	if config.Redaction != MaskNone {
+		logger.Redactor = NewRedactor(config.Redaction)
+	}
+	if config.MemoryRecords > 0 {
+		logger.AddOutput(Output{Name: MemoryOutputName, Level: config.Level, Sink: NewRingBufferSink(config.MemoryRecords)})
+	}
+	if config.SyslogAddress != constant.EmptyString {
+		syslogSink := NewSyslogSink(config.SyslogNetwork, config.SyslogAddress, config)
+		logger.AddOutput(Output{Name: SyslogOutputName, Level: config.Level, Sink: syslogSink})
+		go logger.reportErrors(SyslogOutputName, syslogSink.Errors())
+	}
+	if config.LokiURL != constant.EmptyString {
+		lokiSink := NewLokiSink(config.LokiURL, config)
+		logger.AddOutput(Output{Name: LokiOutputName, Level: config.Level, Sink: lokiSink})
+		go logger.reportErrors(LokiOutputName, lokiSink.Errors())
+	}
+	if config.ElasticsearchURL != constant.EmptyString {
+		elasticsearchSink := NewElasticsearchSink(config.ElasticsearchURL, config)
+		logger.AddOutput(Output{Name: ElasticsearchOutputName, Level: config.Level, Sink: elasticsearchSink})
+		go logger.reportErrors(ElasticsearchOutputName, elasticsearchSink.Errors())
+	}
+	if config.SamplingFirst > 0 {
+		logger.SetSampler(NewSampler(config.SamplingInterval, config.SamplingFirst, config.SamplingThereafter))
+	}
+
+	host, token, source, sourcetype, index, splunkInfoIsFullSetInEnv := GetSplunkInformationFromEnvironment()
+	if splunkInfoIsFullSetInEnv {
+		splunkSink := NewSplunkSink(splunk.NewClient(
+			nil,
+			host,
+			token,
+			source,
+			sourcetype,
+			index,
+		))
+		splunkSink.ServiceName = config.ServiceName
+		splunkSink.Routes = GetSplunkRoutesFromEnvironment()
+		// SPLUNK_SPILL_DIR keeps the batches on disk while Splunk is unreachable
+		if spillDir, isSpillDirSet := os.LookupEnv("SPLUNK_SPILL_DIR"); isSpillDirSet && spillDir != constant.EmptyString {
+			splunkSink.Writer.Spill = &splunk.SpillOptions{Dir: spillDir}
+		}
+		logger.AddOutput(Output{
+			Name:  SplunkOutputName,
+			Level: config.Level,
+			Sink:  splunkSink,
+		})
+		go logger.reportErrors(SplunkOutputName, splunkSink.Errors())
+	}
+	return logger
+}
+
+func formatterOrText(name string) Formatter {
+	formatter, newFormatterError := NewFormatter(name)
+	if newFormatterError != nil {
+		log.Warn(newFormatterError.Error() + ", falling back to text")
+		return &TextFormatter{}
+	}
+	return formatter
+}
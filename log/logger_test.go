@@ -0,0 +1,130 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+type recordingSink struct {
+	records []*Record
+	err     error
+}
+
+func (s *recordingSink) Write(record *Record) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestLogger_WithLevel(t *testing.T) {
+	all := &recordingSink{}
+	warnOnly := &recordingSink{}
+	failing := &recordingSink{err: errors.New("broken sink")}
+	var failedOutputs []string
+	logger := NewLogger(
+		Output{Name: "all", Sink: all},
+		Output{Name: "warn", Level: constant.Warn, Sink: warnOnly},
+		Output{Name: "failing", Sink: failing},
+	)
+	logger.ErrorHandler = func(output string, record *Record, err error) {
+		failedOutputs = append(failedOutputs, output)
+	}
+
+	ctx := context.WithValue(context.Background(), constant.TraceIdLogKey, "trace")
+	ctx = context.WithValue(ctx, constant.UsernameLogKey, "user")
+	logger.WithLevel(constant.Debug, ctx, "debug message")
+	logger.WithLevel(constant.Error, ctx, "error message")
+
+	if len(all.records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(all.records))
+	}
+	if len(warnOnly.records) != 1 || warnOnly.records[0].Message != "error message" {
+		t.Errorf("Expected only the error record to pass the WARN output, got %v", warnOnly.records)
+	}
+	if record := all.records[0]; record.TraceId != "trace" || record.Username != "user" {
+		t.Errorf("Expected trace and user from context, got %q and %q", record.TraceId, record.Username)
+	}
+	if len(failedOutputs) != 2 || failedOutputs[0] != "failing" {
+		t.Errorf("Expected the error handler to be called for the failing output, got %v", failedOutputs)
+	}
+
+	if !logger.RemoveOutput("failing") || len(logger.Outputs()) != 2 {
+		t.Errorf("Expected the failing output to be removed")
+	}
+}
+
+func TestLogger_Levels(t *testing.T) {
+	sink := &recordingSink{}
+	logger := NewLogger(Output{Name: "test", Level: constant.Info, Sink: sink})
+
+	logger.WithLevel(constant.Trace, context.Background(), "filtered out")
+	if err := logger.SetOutputLevel("test", constant.Trace); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	logger.WithLevel(constant.Trace, context.Background(), "trace message")
+	if len(sink.records) != 1 || sink.records[0].Message != "trace message" {
+		t.Fatalf("Expected only the trace message after lowering the level, got %v", sink.records)
+	}
+	if err := logger.SetOutputLevel("unknown", constant.Info); err == nil {
+		t.Errorf("Expected an error for an unknown output")
+	}
+
+	// the package level of a parent package overrides the output level
+	logger.SetPackageLevel("github.com/tuanloc1105/go-common-lib", constant.Error)
+	logger.WithLevel(constant.Warn, context.Background(), "filtered by package")
+	logger.WithLevel(constant.Fatal, context.Background(), "fatal message")
+	if len(sink.records) != 2 || sink.records[1].Level != constant.Fatal {
+		t.Fatalf("Expected the package level to filter the warning, got %v", sink.records)
+	}
+	if sink.records[1].Caller.Package() != "github.com/tuanloc1105/go-common-lib/log" {
+		t.Errorf("Unexpected caller package %q", sink.records[1].Caller.Package())
+	}
+
+	logger.SetPackageLevel("github.com/tuanloc1105/go-common-lib", constant.EmptyString)
+	if levels := logger.Levels(); len(levels.Packages) != 0 || levels.Outputs["test"] != constant.Trace {
+		t.Errorf("Unexpected levels %+v", levels)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("trace"); err != nil || level != constant.Trace {
+		t.Errorf("Expected TRACE, got %q, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+}
+
+func panickingFunction() {
+	panic("boom")
+}
+
+func TestLogger_WithStack(t *testing.T) {
+	sink := &recordingSink{}
+	logger := NewLogger(Output{Name: "test", Sink: sink})
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.WithStack(constant.Error, context.Background(), "recovered")
+			}
+		}()
+		panickingFunction()
+	}()
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(sink.records))
+	}
+	record := sink.records[0]
+	if !strings.HasSuffix(record.Caller.Function, ".panickingFunction") {
+		t.Errorf("Expected the panicking function as caller, got %q", record.Caller.Function)
+	}
+	if strings.Contains(record.Stack, "runtime.gopanic") || !strings.Contains(record.Stack, "TestLogger_WithStack") {
+		t.Errorf("Unexpected stack %q", record.Stack)
+	}
+	if !strings.Contains(record.FormattedMessage(), record.Stack) {
+		t.Errorf("Expected the stack in the formatted message")
+	}
+}
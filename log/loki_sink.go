@@ -0,0 +1,189 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const (
+	lokiPushPath       = "/loki/api/v1/push"
+	defaultHTTPTimeout = 20 * time.Second
+	// maxErrorBodySize is how much of an error response is kept in the reported error
+	maxErrorBodySize = 512
+)
+
+// LokiSink pushes records to Grafana Loki.
+// Every record is labelled with its level, the service and the host, plus the static Labels, the line is
+// encoded by the Formatter (JSON by default). Records are batched like splunk.Writer, see BatchOptions,
+// delivery failures are reported on Errors.
+type LokiSink struct {
+	// URL is the base URL of Loki, e.g. http://loki:3100
+	URL        string
+	HTTPClient *http.Client
+	Formatter  Formatter
+	// Labels are added to the level, service and host labels of every stream
+	Labels      map[string]string
+	ServiceName string
+	Hostname    string
+	// TenantId is sent as X-Scope-OrgID when Loki runs in multi-tenant mode
+	TenantId string
+	Username string
+	Password string
+	BatchOptions
+	batcher    batcher
+	httpClient defaultHTTPClient
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPushRequest struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+// NewLokiSink creates a Loki sink pushing to the Loki at url, the service name of the config is the service label.
+func NewLokiSink(url string, config Config) *LokiSink {
+	hostname, _ := os.Hostname()
+	return &LokiSink{
+		URL:         url,
+		ServiceName: config.ServiceName,
+		Hostname:    hostname,
+	}
+}
+
+func (s *LokiSink) Write(record *Record) error {
+	return s.batcher.add(s.BatchOptions, s.send, record)
+}
+
+// Errors returns the channel of the batches that could not be pushed.
+func (s *LokiSink) Errors() <-chan error {
+	return s.batcher.errorChannel(s.BatchOptions, s.send)
+}
+
+// Dropped returns the number of records dropped because the buffer was full,
+// or because they were not sent when Close gave up.
+func (s *LokiSink) Dropped() int64 {
+	return s.batcher.droppedCount()
+}
+
+// Close stops accepting records and sends the buffered ones, until the context ends.
+func (s *LokiSink) Close(ctx context.Context) error {
+	return s.batcher.close(ctx, s.BatchOptions, s.send)
+}
+
+func (s *LokiSink) send(records []*Record) ([]*Record, error) {
+	body, encodeError := s.encode(records)
+	if encodeError != nil {
+		return nil, encodeError
+	}
+	request, newRequestError := http.NewRequest(http.MethodPost, strings.TrimRight(s.URL, "/")+lokiPushPath, bytes.NewReader(body))
+	if newRequestError != nil {
+		return nil, newRequestError
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.TenantId != constant.EmptyString {
+		request.Header.Set("X-Scope-OrgID", s.TenantId)
+	}
+	if s.Username != constant.EmptyString || s.Password != constant.EmptyString {
+		request.SetBasicAuth(s.Username, s.Password)
+	}
+	response, doError := s.httpClient.get(s.HTTPClient).Do(request)
+	if doError != nil {
+		return records, doError
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil, nil
+	}
+	statusError := responseError("loki", response)
+	// a rejected push is rejected again, only a throttled or failing Loki is worth a retry
+	if isRetryableStatus(response.StatusCode) {
+		return records, withRetryAfter(statusError, response)
+	}
+	return nil, statusError
+}
+
+// encode groups the records in one stream per label set, keeping their order.
+func (s *LokiSink) encode(records []*Record) ([]byte, error) {
+	formatter := s.Formatter
+	if formatter == nil {
+		formatter = &JSONFormatter{}
+	}
+	streams := make(map[string]*lokiStream)
+	pushRequest := lokiPushRequest{}
+	for _, record := range records {
+		line, formatError := formatter.Format(record)
+		if formatError != nil {
+			return nil, formatError
+		}
+		level := strings.ToLower(string(record.Level))
+		stream, isStreamFound := streams[level]
+		if !isStreamFound {
+			stream = &lokiStream{Stream: s.labels(level)}
+			streams[level] = stream
+			pushRequest.Streams = append(pushRequest.Streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(record.Time.UnixNano(), 10),
+			strings.TrimRight(string(line), "\n"),
+		})
+	}
+	return json.Marshal(pushRequest)
+}
+
+func (s *LokiSink) labels(level string) map[string]string {
+	labels := make(map[string]string, len(s.Labels)+3)
+	for key, value := range s.Labels {
+		labels[key] = value
+	}
+	labels["level"] = level
+	if s.ServiceName != constant.EmptyString {
+		labels["service"] = s.ServiceName
+	}
+	if s.Hostname != constant.EmptyString {
+		labels["host"] = s.Hostname
+	}
+	return labels
+}
+
+// defaultHTTPClient is the client of a sink without HTTPClient, built once so its connections are reused
+type defaultHTTPClient struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// get returns client, or the default client when it is nil
+func (d *defaultHTTPClient) get(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	d.once.Do(func() {
+		d.client = &http.Client{Timeout: defaultHTTPTimeout}
+	})
+	return d.client
+}
+
+// isRetryableStatus tells whether a request failing with the status may succeed later.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// responseError builds the error of an unexpected response, keeping the beginning of its body.
+func responseError(service string, response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+	return fmt.Errorf("%s responded %s: %s", service, response.Status, strings.TrimSpace(string(body)))
+}
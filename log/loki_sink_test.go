@@ -0,0 +1,190 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestLokiSink(t *testing.T) {
+	requests := make(chan lokiPushRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != lokiPushPath {
+			t.Errorf("Expected a push to %s, got %s", lokiPushPath, r.URL.Path)
+		}
+		if r.Header.Get("X-Scope-OrgID") != "tenant" {
+			t.Errorf("Expected the tenant header, got %q", r.Header.Get("X-Scope-OrgID"))
+		}
+		var pushRequest lokiPushRequest
+		if err := json.NewDecoder(r.Body).Decode(&pushRequest); err != nil {
+			t.Errorf("Failed to decode the push request: %v", err)
+		}
+		requests <- pushRequest
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := &LokiSink{
+		URL:          server.URL,
+		ServiceName:  "billing",
+		Hostname:     "pod-1",
+		TenantId:     "tenant",
+		Labels:       map[string]string{"env": "test"},
+		BatchOptions: BatchOptions{FlushThreshold: 3, FlushInterval: time.Hour},
+	}
+	now := time.Date(2024, 5, 3, 10, 20, 30, 0, time.UTC)
+	for i, level := range []constant.LogLevelType{constant.Info, constant.Error, constant.Info} {
+		if err := sink.Write(&Record{Time: now.Add(time.Duration(i)), Level: level, TraceId: "trace", Message: "message " + string(level)}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	var pushRequest lokiPushRequest
+	select {
+	case pushRequest = <-requests:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the push")
+	}
+	if len(pushRequest.Streams) != 2 {
+		t.Fatalf("Expected one stream per level, got %d", len(pushRequest.Streams))
+	}
+	info := pushRequest.Streams[0]
+	expectedLabels := map[string]string{"level": "info", "service": "billing", "host": "pod-1", "env": "test"}
+	for key, value := range expectedLabels {
+		if info.Stream[key] != value {
+			t.Errorf("Expected label %s=%s, got %q", key, value, info.Stream[key])
+		}
+	}
+	if len(info.Values) != 2 || info.Values[0][0] != "1714731630000000000" || info.Values[1][0] != "1714731630000000002" {
+		t.Errorf("Expected the two info lines in order, got %v", info.Values)
+	}
+	if !strings.Contains(info.Values[0][1], `"traceId":"trace"`) || strings.HasSuffix(info.Values[0][1], "\n") {
+		t.Errorf("Expected a JSON line without line feed, got %q", info.Values[0][1])
+	}
+	if pushRequest.Streams[1].Stream["level"] != "error" {
+		t.Errorf("Expected the error stream, got %v", pushRequest.Streams[1].Stream)
+	}
+}
+
+func TestLokiSink_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := &LokiSink{URL: server.URL, BatchOptions: BatchOptions{FlushThreshold: 1}}
+	_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "retried"})
+	deadline := time.Now().Add(3 * time.Second)
+	for attempts.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if attempts.Load() != 2 {
+		t.Fatalf("Expected the failed push to be retried once, got %d attempts", attempts.Load())
+	}
+	select {
+	case err := <-sink.Errors():
+		t.Errorf("Expected no error after a successful retry, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLokiSink_PermanentFailure(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "entry too far behind", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := &LokiSink{URL: server.URL, BatchOptions: BatchOptions{FlushThreshold: 1, MaxRetries: 5}}
+	_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "rejected"})
+	select {
+	case err := <-sink.Errors():
+		if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "entry too far behind") {
+			t.Errorf("Expected the status and body in the error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the error")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected a rejected push not to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestLokiSink_RetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	pushed := make(chan time.Time, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushed <- time.Now()
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := &LokiSink{URL: server.URL, BatchOptions: BatchOptions{FlushThreshold: 1, RetryBackoff: time.Millisecond}}
+	_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "throttled"})
+	first, second := <-pushed, <-pushed
+	if waited := second.Sub(first); waited < 900*time.Millisecond {
+		t.Errorf("Expected the retry to wait for the Retry-After delay, waited %v", waited)
+	}
+}
+
+func TestBatcher_RetryDelay(t *testing.T) {
+	options := BatchOptions{RetryBackoff: 100 * time.Millisecond, MaxRetryBackoff: time.Second}
+	for attempt, maximum := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := retryDelay(options, errors.New("connection refused"), attempt)
+		if delay < maximum/2 || delay > maximum {
+			t.Errorf("Expected a delay between %v and %v for attempt %d, got %v", maximum/2, maximum, attempt, delay)
+		}
+	}
+	throttled := &retryAfterError{delay: time.Hour, err: errors.New("loki responded 429")}
+	if delay := retryDelay(options, throttled, 0); delay != time.Second {
+		t.Errorf("Expected the Retry-After delay capped to MaxRetryBackoff, got %v", delay)
+	}
+}
+
+func TestLokiSink_CloseInterruptsRetry(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink := &LokiSink{URL: server.URL, BatchOptions: BatchOptions{FlushThreshold: 1, MaxRetryBackoff: time.Hour}}
+	_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "unavailable"})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := sink.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Close to give up with its context, got %v", err)
+	}
+	select {
+	case <-sink.batcher.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the retry delay to be interrupted by Close")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected no retry after Close gave up, got %d attempts", attempts.Load())
+	}
+	if sink.Dropped() != 1 {
+		t.Errorf("Expected the record to be dropped, got %d", sink.Dropped())
+	}
+}
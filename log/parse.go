@@ -0,0 +1,169 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// ErrNotRecord is returned by ParseLine for a line that does not start a record,
+// e.g. a stack trace line or the continuation of a multi-line text message.
+var ErrNotRecord = errors.New("line does not start a log record")
+
+// textLinePattern matches the lines of the TextFormatter, see constant.LogPattern
+var textLinePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}): ([A-Z]+) - \[(.*?)\] \[(.*?)\] 👉️ \t(.*)$`)
+
+// textFieldPattern matches the last logfmt key=value pair FormattedMessage writes after the message
+var textFieldPattern = regexp.MustCompile(` ([^\s="]+)=("(?:[^"\\]|\\.)*"|[^\s="]*)$`)
+
+// ParseLine decodes a line written by the TextFormatter, the JSONFormatter or the LogfmtFormatter.
+// The text timestamps carry no time zone, they are read in location, UTC when nil.
+// The key=value pairs ending a text line are read as its fields.
+func ParseLine(line string, location *time.Location) (*Record, error) {
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "{"):
+		return parseJSONLine(line)
+	case strings.HasPrefix(line, "ts="):
+		return parseLogfmtLine(line)
+	default:
+		return parseTextLine(line, location)
+	}
+}
+
+func parseTextLine(line string, location *time.Location) (*Record, error) {
+	match := textLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil, ErrNotRecord
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	timestamp, parseError := time.ParseInLocation(constant.YyyyMmDdHhMmSsFormat, match[1], location)
+	if parseError != nil {
+		return nil, ErrNotRecord
+	}
+	message, fields := splitTextFields(match[5])
+	return &Record{
+		Time:     timestamp,
+		Level:    constant.LogLevelType(match[2]),
+		TraceId:  match[3],
+		Username: match[4],
+		Message:  message,
+		Fields:   fields,
+	}, nil
+}
+
+// splitTextFields splits the fields written after the message of a text line.
+// A message that itself ends with key=value pairs can't be told apart, they are read as fields.
+func splitTextFields(message string) (string, []Field) {
+	end := len(message)
+	for {
+		pair := textFieldPattern.FindStringIndex(message[:end])
+		if pair == nil {
+			break
+		}
+		end = pair[0]
+	}
+	if end == len(message) {
+		return message, nil
+	}
+	var fields []Field
+	decoder := logfmt.NewDecoder(strings.NewReader(message[end+1:]))
+	for decoder.ScanRecord() {
+		for decoder.ScanKeyval() {
+			fields = append(fields, Field{Key: string(decoder.Key()), Value: string(decoder.Value())})
+		}
+	}
+	if decoder.Err() != nil {
+		return message, nil
+	}
+	return message[:end], fields
+}
+
+func parseJSONLine(line string) (*Record, error) {
+	var decoded jsonRecord
+	if unmarshalError := json.Unmarshal([]byte(line), &decoded); unmarshalError != nil {
+		return nil, ErrNotRecord
+	}
+	timestamp, parseError := time.Parse(time.RFC3339Nano, decoded.Timestamp)
+	if parseError != nil {
+		return nil, ErrNotRecord
+	}
+	record := &Record{
+		Time:     timestamp,
+		Level:    constant.LogLevelType(decoded.Level),
+		TraceId:  decoded.TraceId,
+		Username: decoded.Username,
+		Message:  decoded.Message,
+		Caller:   parseCaller(decoded.Caller),
+		Stack:    decoded.Stack,
+	}
+	for key, value := range decoded.Fields {
+		record.Fields = append(record.Fields, Field{Key: key, Value: value})
+	}
+	// the JSON object lost the order of the fields
+	sort.Slice(record.Fields, func(i, j int) bool {
+		return record.Fields[i].Key < record.Fields[j].Key
+	})
+	return record, nil
+}
+
+func parseLogfmtLine(line string) (*Record, error) {
+	decoder := logfmt.NewDecoder(strings.NewReader(line))
+	if !decoder.ScanRecord() {
+		return nil, ErrNotRecord
+	}
+	record := &Record{}
+	isTimeSet := false
+	for decoder.ScanKeyval() {
+		value := string(decoder.Value())
+		switch key := string(decoder.Key()); key {
+		case "ts":
+			timestamp, parseError := time.Parse(time.RFC3339Nano, value)
+			if parseError != nil {
+				return nil, ErrNotRecord
+			}
+			record.Time = timestamp
+			isTimeSet = true
+		case "level":
+			record.Level = constant.LogLevelType(value)
+		case "traceId":
+			record.TraceId = value
+		case "username":
+			record.Username = value
+		case "msg":
+			record.Message = value
+		case "caller":
+			record.Caller = parseCaller(value)
+		case "stack":
+			record.Stack = value
+		default:
+			record.Fields = append(record.Fields, Field{Key: key, Value: value})
+		}
+	}
+	if decoder.Err() != nil || !isTimeSet {
+		return nil, ErrNotRecord
+	}
+	return record, nil
+}
+
+// parseCaller reverses Caller.String, only the short file name and the line are known.
+func parseCaller(caller string) Caller {
+	colon := strings.LastIndex(caller, ":")
+	if colon < 0 {
+		return Caller{File: caller}
+	}
+	line, atoiError := strconv.Atoi(caller[colon+1:])
+	if atoiError != nil {
+		return Caller{File: caller}
+	}
+	return Caller{File: caller[:colon], Line: line}
+}
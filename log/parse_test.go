@@ -0,0 +1,81 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestParseLine_RoundTrip(t *testing.T) {
+	location := time.FixedZone("ICT", 7*60*60)
+	record := &Record{
+		Time:     time.Date(2024, 5, 3, 10, 20, 30, 0, location),
+		Level:    constant.Warn,
+		TraceId:  "trace",
+		Username: "user",
+		Message:  "payment failed",
+		Fields:   []Field{{Key: "roomId", Value: "7"}},
+		Caller:   Caller{File: "/src/service/payment.go", Line: 42},
+	}
+	for _, formatter := range []Formatter{&TextFormatter{}, &JSONFormatter{}, &LogfmtFormatter{}} {
+		line, err := formatter.Format(record)
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		parsed, err := ParseLine(string(line), location)
+		if err != nil {
+			t.Fatalf("ParseLine(%q) failed: %v", line, err)
+		}
+		if !parsed.Time.Equal(record.Time) || parsed.Level != record.Level || parsed.TraceId != record.TraceId ||
+			parsed.Username != record.Username || parsed.Message != record.Message {
+			t.Errorf("Unexpected record %+v parsed from %q", parsed, line)
+		}
+		if len(parsed.Fields) != 1 || parsed.Fields[0].Key != "roomId" || parsed.Fields[0].Value != "7" {
+			t.Errorf("Expected the fields, got %+v", parsed.Fields)
+		}
+		if _, isText := formatter.(*TextFormatter); isText {
+			continue
+		}
+		if parsed.Caller.String() != "service/payment.go:42" {
+			t.Errorf("Expected the caller, got %+v", parsed.Caller)
+		}
+	}
+}
+
+func TestParseLine_TextFields(t *testing.T) {
+	record := &Record{
+		Time:    time.Date(2024, 5, 3, 10, 20, 30, 0, time.UTC),
+		Level:   constant.Info,
+		Message: "room joined by a=b c",
+		Fields:  []Field{{Key: "roomId", Value: "7"}, {Key: "reason", Value: "seat taken"}, {Key: "empty", Value: ""}},
+	}
+	line, err := (&TextFormatter{}).Format(record)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	parsed, err := ParseLine(string(line), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine(%q) failed: %v", line, err)
+	}
+	if parsed.Message != record.Message {
+		t.Errorf("Expected the message without the fields, got %q", parsed.Message)
+	}
+	if len(parsed.Fields) != 3 || parsed.Fields[0] != record.Fields[0] || parsed.Fields[1] != record.Fields[1] || parsed.Fields[2] != record.Fields[2] {
+		t.Errorf("Expected the fields in order, got %+v", parsed.Fields)
+	}
+}
+
+func TestParseLine_NotRecord(t *testing.T) {
+	for _, line := range []string{
+		"\tservice/payment.go:42",
+		"main.main()",
+		"{not json",
+		"ts=yesterday level=INFO",
+	} {
+		if _, err := ParseLine(line, time.UTC); !errors.Is(err, ErrNotRecord) {
+			t.Errorf("Expected ErrNotRecord for %q, got %v", line, err)
+		}
+	}
+}
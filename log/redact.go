@@ -0,0 +1,285 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// MaskMode decides how a sensitive value is replaced.
+type MaskMode string
+
+const (
+	// MaskFull replaces the whole value with "***"
+	MaskFull MaskMode = "full"
+	// MaskPartial keeps the first 2 and the last 4 characters of values longer than 8 characters
+	MaskPartial MaskMode = "partial"
+	// MaskHash replaces the value with a short salted SHA-256, so equal values can still be correlated
+	MaskHash MaskMode = "hash"
+	// MaskNone leaves the values untouched, it disables the redaction of the default logger
+	MaskNone MaskMode = "none"
+
+	fullMask = "***"
+)
+
+// ParseMaskMode returns the mask mode matching the name, ignoring case.
+func ParseMaskMode(name string) (MaskMode, error) {
+	switch MaskMode(strings.ToLower(strings.TrimSpace(name))) {
+	case MaskFull, constant.EmptyString:
+		return MaskFull, nil
+	case MaskPartial:
+		return MaskPartial, nil
+	case MaskHash:
+		return MaskHash, nil
+	case MaskNone:
+		return MaskNone, nil
+	default:
+		return constant.EmptyString, fmt.Errorf("unknown mask mode %q", name)
+	}
+}
+
+// Detector finds sensitive values in free text.
+// When Pattern has a capturing group only the first group is masked, otherwise the whole match.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate, if set, filters out false positives of the pattern
+	Validate func(value string) bool
+	// Mode overrides the mode of the Redactor when set
+	Mode MaskMode
+}
+
+// keyValuePattern matches a key, optionally quoted, then a separator, then the value up to the next delimiter
+var keyValuePattern = regexp.MustCompile(`(?i)(["']?([A-Za-z][\w-]*)["']?\s*[:=]\s*["']?)((?:bearer\s+)?[^"'&,;\s}\]]+)`)
+
+// DefaultDetectors returns detectors for JWTs, bearer tokens, card numbers, emails, phone numbers and Vietnamese citizen IDs.
+// A card number is only recognized grouped like on the card, e.g. "4111 1111 1111 1111", or after a card key
+// such as "card" or "cardNumber", so that timestamps and numeric IDs passing the Luhn check are left alone.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		{Name: "jwt", Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+		{Name: "bearer", Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)},
+		{
+			Name: "card",
+			Pattern: regexp.MustCompile(
+				`\b(?:\d{4}[ -]){3}\d{1,7}\b|\b\d{4}[ -]\d{6}[ -]\d{5}\b|` +
+					`(?i:\b(?:card|card[_ -]?(?:number|no)|pan|cc[_-]?number)["']?\s*[:=]?\s*["']?(\d{13,19})\b)`,
+			),
+			Validate: luhnValid,
+		},
+		{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+		{Name: "vietnameseCitizenId", Pattern: regexp.MustCompile(`\b0\d{11}\b`)},
+		{Name: "phone", Pattern: regexp.MustCompile(`(?:\+84|\b0)[35789]\d{8}\b|\+[1-9]\d{9,14}\b`)},
+	}
+}
+
+// Redactor masks sensitive data in the records of a Logger.
+//
+// Values following a sensitive key, e.g. `"password":"..."`, `token=...` or `Authorization: ...`, are masked with Mode,
+// then every Detector runs on the remaining text. Fields with a sensitive key are masked as a whole.
+// A key is sensitive when some of its words spell one of the registered keys, see IsSensitiveKey.
+type Redactor struct {
+	Mode MaskMode
+	// Salt is mixed into the hashes of MaskHash
+	Salt      string
+	mu        sync.RWMutex
+	keys      []string
+	detectors []Detector
+}
+
+// NewRedactor creates a redactor with the keys of constant.SensitiveField, the extra keys and DefaultDetectors.
+func NewRedactor(mode MaskMode, keys ...string) *Redactor {
+	r := &Redactor{
+		Mode:      mode,
+		detectors: DefaultDetectors(),
+	}
+	r.AddKeys(append(constant.SensitiveField[:], keys...)...)
+	return r
+}
+
+// AddKeys registers more sensitive key names, matched case-insensitively on whole words of a key.
+// Underscores and dashes are optional, "client_secret" also matches "clientSecret" and "client-secret",
+// "token" matches "access_token" but neither "tokens" nor "maxTokens".
+func (r *Redactor) AddKeys(keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		if key = normalizeKey(strings.TrimSpace(key)); key != constant.EmptyString {
+			r.keys = append(r.keys, key)
+		}
+	}
+}
+
+// AddDetector registers a detector running after the existing ones.
+func (r *Redactor) AddDetector(detector Detector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append(r.detectors, detector)
+}
+
+// IsSensitiveKey reports whether consecutive words of the key spell one of the registered keys.
+// The words of a key are split at underscores, dashes and camel case, "X-API-Key" is made of "x", "api" and "key".
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return false
+	}
+	words := keyWords(key)
+	for i := range words {
+		joined := constant.EmptyString
+		for _, word := range words[i:] {
+			joined += word
+			for _, sensitiveKey := range r.keys {
+				if joined == sensitiveKey {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Redact masks the sensitive values found in the text.
+func (r *Redactor) Redact(text string) string {
+	if text == constant.EmptyString {
+		return text
+	}
+	r.mu.RLock()
+	detectors := r.detectors
+	r.mu.RUnlock()
+
+	text = r.redactKeyValues(text)
+	for _, detector := range detectors {
+		mode := detector.Mode
+		if mode == constant.EmptyString {
+			mode = r.Mode
+		}
+		text = detector.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			groups := detector.Pattern.FindStringSubmatchIndex(match)
+			start, end := 0, len(match)
+			if len(groups) >= 4 && groups[2] >= 0 {
+				start, end = groups[2], groups[3]
+			}
+			value := match[start:end]
+			if isMasked(value) || (detector.Validate != nil && !detector.Validate(value)) {
+				return match
+			}
+			return match[:start] + r.mask(value, mode) + match[end:]
+		})
+	}
+	return text
+}
+
+// redactKeyValues masks the values of the sensitive keys found in the text.
+// The value of another key is searched too, e.g. a URL holding "?token=...".
+func (r *Redactor) redactKeyValues(text string) string {
+	return keyValuePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := keyValuePattern.FindStringSubmatch(match)
+		if r.IsSensitiveKey(groups[2]) {
+			return groups[1] + r.mask(groups[3], r.Mode)
+		}
+		return groups[1] + r.redactKeyValues(groups[3])
+	})
+}
+
+// RedactRecord masks the message and the fields of the record in place.
+func (r *Redactor) RedactRecord(record *Record) {
+	record.Message = r.Redact(record.Message)
+	for i, field := range record.Fields {
+		if r.IsSensitiveKey(field.Key) {
+			record.Fields[i].Value = r.mask(fmt.Sprint(field.Value), r.Mode)
+			continue
+		}
+		if text, isString := field.Value.(string); isString {
+			record.Fields[i].Value = r.Redact(text)
+		}
+	}
+}
+
+func (r *Redactor) mask(value string, mode MaskMode) string {
+	switch mode {
+	case MaskPartial:
+		if len(value) <= 8 {
+			return fullMask
+		}
+		return value[:2] + fullMask + value[len(value)-4:]
+	case MaskHash:
+		sum := sha256.Sum256([]byte(r.Salt + value))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	case MaskNone:
+		return value
+	default:
+		return fullMask
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(key), isKeySeparator), constant.EmptyString)
+}
+
+func isKeySeparator(r rune) bool {
+	return r == '_' || r == '-'
+}
+
+// keyWords splits a key into lowercase words at the characters other than letters and digits and at camel case.
+func keyWords(key string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	for i, c := range runes {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		// "apiKey" and "APIKey" both start a word at the K
+		if unicode.IsUpper(c) && len(word) > 0 &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words = append(words, string(word))
+			word = nil
+		}
+		word = append(word, unicode.ToLower(c))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// isMasked avoids masking twice a value already replaced by a key rule.
+func isMasked(value string) bool {
+	return strings.Contains(value, fullMask) || strings.HasPrefix(value, "sha256:")
+}
+
+// luhnValid checks the Luhn checksum of a card number, ignoring spaces and dashes.
+func luhnValid(value string) bool {
+	sum := 0
+	digits := 0
+	double := false
+	for i := len(value) - 1; i >= 0; i-- {
+		c := rune(value[i])
+		if !unicode.IsDigit(c) {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// RingBufferSink keeps the last records in memory, for inspection when the files and Splunk are out of reach.
+type RingBufferSink struct {
+	mu       sync.RWMutex
+	records  []Record
+	next     int
+	isFilled bool
+}
+
+// RecordFilter selects records of a RingBufferSink, zero values match everything.
+type RecordFilter struct {
+	// Level is the minimum level
+	Level    constant.LogLevelType
+	TraceId  string
+	Username string
+	From     time.Time
+	To       time.Time
+	// Message is a regular expression the message must match
+	Message *regexp.Regexp
+	// Limit keeps the most recent records only
+	Limit int
+}
+
+// NewRingBufferSink creates a sink keeping the last capacity records.
+func NewRingBufferSink(capacity int) *RingBufferSink {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &RingBufferSink{
+		records: make([]Record, capacity),
+	}
+}
+
+func (s *RingBufferSink) Write(record *Record) error {
+	stored := *record
+	// the fields slice may be reused by the caller
+	stored.Fields = append([]Field(nil), record.Fields...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[s.next] = stored
+	s.next = (s.next + 1) % len(s.records)
+	if s.next == 0 {
+		s.isFilled = true
+	}
+	return nil
+}
+
+// Records returns the records matching the filter, oldest first.
+func (s *RingBufferSink) Records(filter RecordFilter) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ordered []Record
+	if s.isFilled {
+		ordered = append(ordered, s.records[s.next:]...)
+	}
+	ordered = append(ordered, s.records[:s.next]...)
+
+	result := make([]Record, 0, len(ordered))
+	for _, record := range ordered {
+		if filter.Matches(&record) {
+			result = append(result, record)
+		}
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// Matches reports whether the record passes the filter, the Limit aside.
+func (f RecordFilter) Matches(record *Record) bool {
+	if !LevelEnabled(f.Level, record.Level) {
+		return false
+	}
+	if f.TraceId != constant.EmptyString && record.TraceId != f.TraceId {
+		return false
+	}
+	if f.Username != constant.EmptyString && record.Username != f.Username {
+		return false
+	}
+	if !f.From.IsZero() && record.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && record.Time.After(f.To) {
+		return false
+	}
+	if f.Message != nil && !f.Message.MatchString(record.Message) {
+		return false
+	}
+	return true
+}
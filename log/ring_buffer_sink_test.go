@@ -0,0 +1,51 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestRingBufferSink_Records(t *testing.T) {
+	sink := NewRingBufferSink(3)
+	start := time.Date(2024, time.May, 3, 10, 0, 0, 0, time.UTC)
+	levels := []constant.LogLevelType{constant.Info, constant.Error, constant.Debug, constant.Error, constant.Warn}
+	for i, level := range levels {
+		_ = sink.Write(&Record{
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Level:    level,
+			TraceId:  fmt.Sprintf("trace-%d", i%2),
+			Username: "user",
+			Message:  fmt.Sprintf("message %d", i),
+		})
+	}
+
+	testCases := []struct {
+		name   string
+		filter RecordFilter
+		expect []string
+	}{
+		{name: "Only the last records are kept", filter: RecordFilter{}, expect: []string{"message 2", "message 3", "message 4"}},
+		{name: "Minimum level", filter: RecordFilter{Level: constant.Warn}, expect: []string{"message 3", "message 4"}},
+		{name: "Trace id", filter: RecordFilter{TraceId: "trace-0"}, expect: []string{"message 2", "message 4"}},
+		{name: "Time range", filter: RecordFilter{From: start.Add(3 * time.Minute), To: start.Add(3 * time.Minute)}, expect: []string{"message 3"}},
+		{name: "Limit", filter: RecordFilter{Limit: 1}, expect: []string{"message 4"}},
+		{name: "Unknown user", filter: RecordFilter{Username: "other"}, expect: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records := sink.Records(tc.filter)
+			if len(records) != len(tc.expect) {
+				t.Fatalf("Expected %v, got %v", tc.expect, records)
+			}
+			for i := range records {
+				if records[i].Message != tc.expect[i] {
+					t.Errorf("Expected %q, got %q", tc.expect[i], records[i].Message)
+				}
+			}
+		})
+	}
+}
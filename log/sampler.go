@@ -0,0 +1,158 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const (
+	defaultSamplingInterval = time.Second
+	maxTemplateLength       = 200
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	numberPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// Sampler limits how many similar records a Logger writes per interval.
+//
+// Records are similar when they have the same level and message template, the template being the message
+// with its numbers and UUIDs masked. Within an interval the First records of a template are written,
+// then one in Thereafter; a Thereafter of 0 drops all of them.
+// When an interval ends, a "N similar messages dropped" record is written for every template that lost records.
+// A Sampler may be built with NewSampler or as a struct literal, an Interval of 0 means one second.
+type Sampler struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+	initOnce   sync.Once
+	mu         sync.Mutex
+	counters   map[string]*sampleCounter
+	stop       chan struct{}
+	stopOnce   sync.Once
+	// stopped is closed once the last summaries are written after Stop
+	stopped chan struct{}
+}
+
+type sampleCounter struct {
+	level    constant.LogLevelType
+	template string
+	count    int
+	dropped  int
+}
+
+// NewSampler creates a sampler, an interval of 0 means one second.
+func NewSampler(interval time.Duration, first int, thereafter int) *Sampler {
+	if interval <= 0 {
+		interval = defaultSamplingInterval
+	}
+	return &Sampler{
+		Interval:   interval,
+		First:      first,
+		Thereafter: thereafter,
+		counters:   make(map[string]*sampleCounter),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// init sets up a sampler built as a struct literal
+func (s *Sampler) init() {
+	s.initOnce.Do(func() {
+		if s.Interval <= 0 {
+			s.Interval = defaultSamplingInterval
+		}
+		if s.counters == nil {
+			s.counters = make(map[string]*sampleCounter)
+		}
+		if s.stop == nil {
+			s.stop = make(chan struct{})
+		}
+		if s.stopped == nil {
+			s.stopped = make(chan struct{})
+		}
+	})
+}
+
+// Allow counts the record and reports whether it should be written.
+func (s *Sampler) Allow(record *Record) bool {
+	s.init()
+	template := messageTemplate(record.Message)
+	key := string(record.Level) + "|" + template
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, isCounterExist := s.counters[key]
+	if !isCounterExist {
+		counter = &sampleCounter{level: record.Level, template: template}
+		s.counters[key] = counter
+	}
+	counter.count++
+	if counter.count <= s.First {
+		return true
+	}
+	if s.Thereafter > 0 && (counter.count-s.First)%s.Thereafter == 0 {
+		return true
+	}
+	counter.dropped++
+	return false
+}
+
+// Summaries ends the current interval and returns one record per template that had records dropped.
+func (s *Sampler) Summaries(location *time.Location) []*Record {
+	s.init()
+	s.mu.Lock()
+	counters := s.counters
+	s.counters = make(map[string]*sampleCounter, len(counters))
+	s.mu.Unlock()
+
+	var summaries []*Record
+	for _, counter := range counters {
+		if counter.dropped == 0 {
+			continue
+		}
+		summaries = append(summaries, &Record{
+			Time:  time.Now().In(location),
+			Level: counter.level,
+			Message: fmt.Sprintf(
+				"%d similar messages dropped in the last %s: %s",
+				counter.dropped,
+				s.Interval,
+				counter.template,
+			),
+			Fields: []Field{
+				{Key: "droppedCount", Value: counter.dropped},
+				{Key: "messageTemplate", Value: counter.template},
+			},
+		})
+	}
+	return summaries
+}
+
+// Stop ends the periodic summaries of the Logger using the sampler.
+func (s *Sampler) Stop() {
+	s.init()
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// messageTemplate masks the variable parts of a message so that similar messages share a key.
+func messageTemplate(message string) string {
+	if len(message) > maxTemplateLength {
+		// cut on a rune boundary, not in the middle of a multi-byte character
+		end := maxTemplateLength
+		for end > 0 && !utf8.RuneStart(message[end]) {
+			end--
+		}
+		message = message[:end]
+	}
+	message = uuidPattern.ReplaceAllString(message, "<uuid>")
+	return numberPattern.ReplaceAllString(message, "<n>")
+}
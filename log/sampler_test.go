@@ -0,0 +1,112 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+type lockedSink struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (s *lockedSink) Write(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *lockedSink) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []string
+	for _, record := range s.records {
+		messages = append(messages, record.Message)
+	}
+	return messages
+}
+
+func TestSampler_Allow(t *testing.T) {
+	sampler := NewSampler(time.Minute, 2, 3)
+	allowed := 0
+	for i := 0; i < 11; i++ {
+		if sampler.Allow(&Record{Level: constant.Error, Message: fmt.Sprintf("call %d failed for 6f1c2a34-9b1e-4c55-8d1e-2f3a4b5c6d7e", i)}) {
+			allowed++
+		}
+	}
+	// 2 first, then the 3rd, 6th and 9th of the remaining 9
+	if allowed != 5 {
+		t.Errorf("Expected 5 records to be allowed, got %d", allowed)
+	}
+	if !sampler.Allow(&Record{Level: constant.Warn, Message: "call 1 failed"}) {
+		t.Errorf("Expected another level to be sampled separately")
+	}
+
+	summaries := sampler.Summaries(time.UTC)
+	if len(summaries) != 1 || !strings.HasPrefix(summaries[0].Message, "6 similar messages dropped") {
+		t.Fatalf("Expected one summary of 6 dropped messages, got %v", summaries)
+	}
+	if len(sampler.Summaries(time.UTC)) != 0 {
+		t.Errorf("Expected the counters to be reset")
+	}
+}
+
+func TestLogger_SetSampler(t *testing.T) {
+	sink := &lockedSink{}
+	logger := NewLogger(Output{Name: "test", Sink: sink})
+	sampler := NewSampler(time.Hour, 1, 0)
+	logger.SetSampler(sampler)
+	for i := 0; i < 5; i++ {
+		logger.WithLevel(constant.Error, context.Background(), fmt.Sprintf("retry %d", i))
+	}
+	sampler.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for len(sink.messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	messages := sink.messages()
+	if len(messages) != 2 || messages[0] != "retry 0" || !strings.HasPrefix(messages[1], "4 similar messages dropped") {
+		t.Errorf("Expected the first message and a summary, got %v", messages)
+	}
+}
+
+func TestMessageTemplate_MultiByte(t *testing.T) {
+	// "đ" takes 2 bytes, the 200th byte is in the middle of one
+	template := messageTemplate("x" + strings.Repeat("đ", 150))
+	if !utf8.ValidString(template) {
+		t.Errorf("Expected a valid UTF-8 template, got %q", template)
+	}
+	if len(template) != 199 {
+		t.Errorf("Expected the template to be cut before the split character, got %d bytes", len(template))
+	}
+}
+
+func TestSampler_StructLiteral(t *testing.T) {
+	sampler := &Sampler{First: 1}
+	record := &Record{Level: constant.Info, Message: "retry 1"}
+	if !sampler.Allow(record) || sampler.Allow(record) {
+		t.Errorf("Expected only the first record to be allowed")
+	}
+	sink := &lockedSink{}
+	logger := NewLogger(Output{Name: "test", Sink: sink})
+	logger.SetSampler(sampler)
+	sampler.Stop()
+	sampler.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := logger.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if messages := sink.messages(); len(messages) != 1 || !strings.HasPrefix(messages[0], "1 similar messages dropped") {
+		t.Errorf("Expected the summary written on Stop, got %q", messages)
+	}
+}
@@ -0,0 +1,146 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrSinkClosed is returned by the sinks written after they are closed.
+var ErrSinkClosed = errors.New("log sink is closed")
+
+// Closer is implemented by the sinks holding buffered records, Close delivers them before the context ends.
+// Sinks implementing io.Closer are closed by Shutdown as well.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// ShutdownError reports the outputs that could not be closed in time and the records they lost.
+type ShutdownError struct {
+	// Errors holds the close error of each failing output
+	Errors map[string]error
+	// Dropped holds the number of records lost by each output, see the Dropped method of the sinks
+	Dropped map[string]int64
+}
+
+func (e *ShutdownError) Error() string {
+	var problems []string
+	for name, err := range e.Errors {
+		problems = append(problems, fmt.Sprintf("%s: %s", name, err.Error()))
+	}
+	for name, dropped := range e.Dropped {
+		problems = append(problems, fmt.Sprintf("%s dropped %d records", name, dropped))
+	}
+	sort.Strings(problems)
+	return "log shutdown: " + strings.Join(problems, ", ")
+}
+
+// Shutdown stops accepting records, writes the last sampling summaries and closes every output,
+// letting the buffered sinks deliver what they hold until the context ends.
+// It returns a *ShutdownError when an output fails to close or has dropped records.
+func (l *Logger) Shutdown(ctx context.Context) error {
+	if !l.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if sampler := l.sampler.Swap(nil); sampler != nil {
+		sampler.Stop()
+		select {
+		case <-sampler.stopped:
+		case <-ctx.Done():
+		}
+	}
+
+	type closeResult struct {
+		output  string
+		err     error
+		dropped int64
+	}
+	outputs := l.Outputs()
+	results := make(chan closeResult, len(outputs))
+	for _, output := range outputs {
+		go func(output Output) {
+			result := closeResult{output: output.Name, err: closeSink(ctx, output.Sink)}
+			if droppingSink, isDroppingSink := output.Sink.(interface{ Dropped() int64 }); isDroppingSink {
+				result.dropped = droppingSink.Dropped()
+			}
+			results <- result
+		}(output)
+	}
+	shutdownError := &ShutdownError{
+		Errors:  make(map[string]error),
+		Dropped: make(map[string]int64),
+	}
+	for range outputs {
+		result := <-results
+		if result.err != nil {
+			shutdownError.Errors[result.output] = result.err
+		}
+		if result.dropped > 0 {
+			shutdownError.Dropped[result.output] = result.dropped
+		}
+	}
+	if len(shutdownError.Errors) == 0 && len(shutdownError.Dropped) == 0 {
+		return nil
+	}
+	return shutdownError
+}
+
+// closeSink closes the sink if it holds resources, without waiting past the end of the context.
+func closeSink(ctx context.Context, sink Sink) error {
+	switch closer := sink.(type) {
+	case Closer:
+		return closer.Close(ctx)
+	case io.Closer:
+		closed := make(chan error, 1)
+		go func() {
+			closed <- closer.Close()
+		}()
+		select {
+		case closeError := <-closed:
+			return closeError
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	default:
+		return nil
+	}
+}
+
+// Shutdown shuts the default logger down, see Logger.Shutdown. It does nothing if the default logger was never used.
+func Shutdown(ctx context.Context) error {
+	logger := defaultLogger.Load()
+	if logger == nil {
+		return nil
+	}
+	return logger.Shutdown(ctx)
+}
+
+// ShutdownOnSignal shuts the default logger down within timeout when the process receives one of the signals,
+// SIGTERM and SIGINT when none is given. Kubernetes sends SIGTERM when it terminates a pod, the timeout should be
+// shorter than the termination grace period of the pod.
+//
+// The signals no longer terminate the process: the returned channel receives the result of Shutdown, the
+// application should exit once it has.
+func ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	result := make(chan error, 1)
+	go func() {
+		<-received
+		signal.Stop(received)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result <- Shutdown(ctx)
+	}()
+	return result
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+type closingSink struct {
+	recordingSink
+	closed bool
+}
+
+func (s *closingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestLogger_Shutdown(t *testing.T) {
+	var pushed atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushed.Add(int32(strings.Count(string(body), `"message`)))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// nothing would be pushed before the end of the test without Shutdown
+	lokiSink := &LokiSink{URL: server.URL, BatchOptions: BatchOptions{FlushThreshold: 1000, FlushInterval: time.Hour}}
+	fileSink := &closingSink{}
+	logger := NewLogger(
+		Output{Name: LokiOutputName, Sink: lokiSink},
+		Output{Name: FileOutputName, Sink: fileSink},
+	)
+	for i := 0; i < 5; i++ {
+		logger.WithLevel(constant.Info, context.Background(), "buffered")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := logger.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if pushed.Load() != 5 {
+		t.Errorf("Expected the buffered records to be pushed, got %d", pushed.Load())
+	}
+	if !fileSink.closed {
+		t.Errorf("Expected the io.Closer sink to be closed")
+	}
+	logger.WithLevel(constant.Info, context.Background(), "too late")
+	if len(fileSink.records) != 5 {
+		t.Errorf("Expected the records to be discarded after Shutdown, got %d records", len(fileSink.records))
+	}
+	if err := lokiSink.Write(&Record{Message: "too late"}); !errors.Is(err, ErrSinkClosed) {
+		t.Errorf("Expected ErrSinkClosed from a closed sink, got %v", err)
+	}
+}
+
+func TestLogger_ShutdownReportsDropped(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	// the collector is down for the whole test
+	_ = listener.Close()
+	syslogSink := &SyslogSink{Network: SyslogTCP, Address: address, ReconnectInterval: 10 * time.Millisecond}
+	logger := NewLogger(Output{Name: SyslogOutputName, Sink: syslogSink})
+	for i := 0; i < 3; i++ {
+		logger.WithLevel(constant.Error, context.Background(), "lost")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = logger.Shutdown(ctx)
+	var shutdownError *ShutdownError
+	if !errors.As(err, &shutdownError) {
+		t.Fatalf("Expected a ShutdownError, got %v", err)
+	}
+	if !errors.Is(shutdownError.Errors[SyslogOutputName], context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to be reported, got %v", shutdownError.Errors)
+	}
+	if shutdownError.Dropped[SyslogOutputName] != 3 {
+		t.Errorf("Expected 3 dropped records, got %v", shutdownError.Dropped)
+	}
+	if !strings.Contains(err.Error(), "syslog dropped 3 records") {
+		t.Errorf("Unexpected error message %q", err.Error())
+	}
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	previous := defaultLogger.Load()
+	defer defaultLogger.Store(previous)
+	sink := &closingSink{}
+	SetDefault(NewLogger(Output{Name: FileOutputName, Sink: sink}))
+
+	result := ShutdownOnSignal(time.Second, os.Interrupt)
+	process, _ := os.FindProcess(os.Getpid())
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("Can not signal the test process: %v", err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Shutdown failed: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the shutdown")
+	}
+	if !sink.closed {
+		t.Errorf("Expected the default logger to be shut down")
+	}
+}
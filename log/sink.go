@@ -0,0 +1,186 @@
+package log
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/utils/splunk/v2"
+)
+
+// ConsoleSink prints records on the console.
+// Without a Formatter it uses a charmbracelet logger writing to Out, otherwise the formatted bytes are written to Out.
+// The console does not filter levels by itself, use the level of its Output.
+type ConsoleSink struct {
+	Formatter Formatter
+	Out       io.Writer // defaults to os.Stderr
+	mu        sync.Mutex
+	console   *log.Logger
+}
+
+// NewConsoleSink creates a console sink, a nil formatter keeps the colored charmbracelet output.
+func NewConsoleSink(formatter Formatter) *ConsoleSink {
+	return &ConsoleSink{
+		Formatter: formatter,
+		Out:       os.Stderr,
+	}
+}
+
+// out returns Out, os.Stderr when it is not set
+func (s *ConsoleSink) out() io.Writer {
+	if s.Out == nil {
+		return os.Stderr
+	}
+	return s.Out
+}
+
+func (s *ConsoleSink) charmLogger() *log.Logger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.console == nil {
+		s.console = log.NewWithOptions(s.out(), log.Options{
+			ReportTimestamp: true,
+			Level:           log.DebugLevel,
+		})
+	}
+	return s.console
+}
+
+func (s *ConsoleSink) Write(record *Record) error {
+	if s.Formatter == nil {
+		message := record.FormattedMessage()
+		console := s.charmLogger()
+		switch record.Level {
+		case constant.Trace, constant.Debug:
+			console.Debug(
+				message,
+			)
+		case constant.Info:
+			console.Info(
+				message,
+			)
+		case constant.Warn:
+			console.Warn(
+				message,
+			)
+		case constant.Error:
+			console.Error(
+				message,
+			)
+		case constant.Fatal:
+			// Log instead of Fatal, the charmbracelet Fatal exits the process
+			console.Log(
+				log.FatalLevel,
+				message,
+			)
+		default:
+			console.Info(
+				message,
+			)
+		}
+		return nil
+	}
+	b, formatError := s.Formatter.Format(record)
+	if formatError != nil {
+		return formatError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, writeError := s.out().Write(b)
+	return writeError
+}
+
+// SplunkSink sends records to the Splunk HTTP Event Collector.
+// Records are queued on a long-lived splunk.Writer and delivered in batches from its own goroutine,
+// so Write returns without waiting for Splunk. Delivery failures are reported on Errors.
+//
+// Each record is sent as an object with level, traceId, username, message, service, caller, stack and
+// the fields of the record, level and traceId being HEC indexed fields as well.
+// Routes send the records of a level to their own index, source type or source.
+type SplunkSink struct {
+	Writer      *splunk.Writer
+	ServiceName string
+	Routes      map[constant.LogLevelType]SplunkRoute
+}
+
+// SplunkRoute overrides the index, source type and source of the client for a level, empty values keep the client ones.
+type SplunkRoute struct {
+	Index      string
+	SourceType string
+	Source     string
+}
+
+// NewSplunkSink creates a Splunk sink batching records through a new splunk.Writer for the client.
+func NewSplunkSink(client *splunk.Client) *SplunkSink {
+	return &SplunkSink{
+		Writer: &splunk.Writer{
+			Client: client,
+		},
+	}
+}
+
+// splunkReservedKeys are the keys of the event object, the record fields with the same key are sent as "fields.key"
+var splunkReservedKeys = map[string]bool{
+	"level": true, "traceId": true, "username": true, "message": true, "service": true, "caller": true, "stack": true,
+}
+
+func (s *SplunkSink) Write(record *Record) error {
+	event := make(map[string]any, len(record.Fields)+7)
+	for _, field := range record.Fields {
+		key := field.Key
+		if splunkReservedKeys[key] {
+			key = "fields." + key
+		}
+		event[key] = jsonValue(field.Value)
+	}
+	event["level"] = string(record.Level)
+	event["traceId"] = record.TraceId
+	event["username"] = record.Username
+	event["message"] = record.Message
+	if s.ServiceName != constant.EmptyString {
+		event["service"] = s.ServiceName
+	}
+	if caller := record.Caller.String(); caller != constant.EmptyString {
+		event["caller"] = caller
+	}
+	if record.Stack != constant.EmptyString {
+		event["stack"] = record.Stack
+	}
+	indexedFields := map[string]any{"level": string(record.Level)}
+	if record.TraceId != constant.EmptyString {
+		indexedFields["traceId"] = record.TraceId
+	}
+	// empty route values are filled by the writer with the client configuration
+	route := s.Routes[record.Level]
+	return s.Writer.WriteEvent(&splunk.Event{
+		Time:       splunk.EventTime{Time: record.Time},
+		Source:     route.Source,
+		SourceType: route.SourceType,
+		Index:      route.Index,
+		Event:      event,
+		Fields:     indexedFields,
+	})
+}
+
+// Flush delivers the buffered records to Splunk, until the context ends.
+func (s *SplunkSink) Flush(ctx context.Context) error {
+	return s.Writer.Flush(ctx)
+}
+
+// Close stops accepting records and delivers the buffered ones to Splunk, until the context ends.
+func (s *SplunkSink) Close(ctx context.Context) error {
+	return s.Writer.Close(ctx)
+}
+
+// Errors returns the channel of errors hit while delivering batches to Splunk.
+func (s *SplunkSink) Errors() <-chan error {
+	return s.Writer.Errors()
+}
+
+// Dropped returns the number of records lost by the splunk.Writer, see splunk.Writer.Dropped.
+func (s *SplunkSink) Dropped() int64 {
+	return s.Writer.Dropped()
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/utils/splunk/v2"
+)
+
+type splunkTestEvent struct {
+	Index  string         `json:"index"`
+	Source string         `json:"source"`
+	Event  map[string]any `json:"event"`
+	Fields map[string]any `json:"fields"`
+}
+
+func TestSplunkSink(t *testing.T) {
+	var events []splunkTestEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		for _, line := range strings.Split(string(body), "\r\n\r\n") {
+			if line == constant.EmptyString {
+				continue
+			}
+			var event splunkTestEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Errorf("Failed to decode %q: %v", line, err)
+			}
+			events = append(events, event)
+		}
+	}))
+	defer server.Close()
+
+	sink := NewSplunkSink(splunk.NewClient(server.Client(), server.URL, "token", "app", "_json", "main"))
+	sink.ServiceName = "billing"
+	sink.Routes = map[constant.LogLevelType]SplunkRoute{constant.Error: {Index: "errors"}}
+	now := time.Now()
+	_ = sink.Write(&Record{Time: now, Level: constant.Info, TraceId: "trace", Username: "user", Message: "room joined",
+		Fields: []Field{{Key: "roomId", Value: 7}, {Key: "level", Value: "shadowed"}}})
+	_ = sink.Write(&Record{Time: now, Level: constant.Error, Message: "payment failed", Fields: []Field{{Key: "cause", Value: errors.New("timeout")}}})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	info := events[0]
+	expected := map[string]any{
+		"level": "INFO", "traceId": "trace", "username": "user", "message": "room joined", "service": "billing",
+		"roomId": float64(7), "fields.level": "shadowed",
+	}
+	for key, value := range expected {
+		if info.Event[key] != value {
+			t.Errorf("Expected %s=%v in the event, got %v", key, value, info.Event[key])
+		}
+	}
+	if info.Fields["level"] != "INFO" || info.Fields["traceId"] != "trace" {
+		t.Errorf("Expected level and traceId as indexed fields, got %v", info.Fields)
+	}
+	if info.Index != "main" || info.Source != "app" {
+		t.Errorf("Expected the client index and source, got %s %s", info.Index, info.Source)
+	}
+	if events[1].Index != "errors" || events[1].Source != "app" {
+		t.Errorf("Expected the error route, got %s %s", events[1].Index, events[1].Source)
+	}
+	if events[1].Event["cause"] != "timeout" {
+		t.Errorf("Expected the error text, got %v", events[1].Event["cause"])
+	}
+	if _, hasTraceId := events[1].Fields["traceId"]; hasTraceId {
+		t.Errorf("Expected no empty traceId indexed field, got %v", events[1].Fields)
+	}
+}
+
+func TestGetSplunkRoutesFromEnvironment(t *testing.T) {
+	t.Setenv("SPLUNK_INDEX_ERROR", "errors")
+	t.Setenv("SPLUNK_SOURCETYPE_FATAL", "fatal")
+	routes := GetSplunkRoutesFromEnvironment()
+	if len(routes) != 2 || routes[constant.Error].Index != "errors" || routes[constant.Fatal].SourceType != "fatal" {
+		t.Errorf("Unexpected routes %v", routes)
+	}
+}
+
+func TestConsoleSink_FormatterDefaultOut(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create a pipe: %v", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() {
+		os.Stderr = stderr
+	}()
+	sink := &ConsoleSink{Formatter: &JSONFormatter{}}
+	writeError := sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "to stderr"})
+	_ = writer.Close()
+	os.Stderr = stderr
+	if writeError != nil {
+		t.Fatalf("Write failed: %v", writeError)
+	}
+	b, _ := io.ReadAll(reader)
+	if !strings.Contains(string(b), `"message":"to stderr"`) {
+		t.Errorf("Expected the record on os.Stderr, got %q", b)
+	}
+}
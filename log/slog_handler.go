@@ -0,0 +1,144 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// SlogHandler is a slog.Handler writing to a Logger.
+// The traceId and username are read from the context like in WithLevel, attributes become record fields.
+// Attributes inside groups are flattened as "group.key".
+type SlogHandler struct {
+	logger *Logger
+	level  slog.Leveler
+	fields []Field
+	prefix string
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler creates a handler writing to the logger, a nil logger uses Default.
+// Records below the level are discarded, a nil level accepts everything.
+func NewSlogHandler(logger *Logger, level slog.Leveler) *SlogHandler {
+	return &SlogHandler{
+		logger: logger,
+		level:  level,
+	}
+}
+
+// NewSlogLogger returns a *slog.Logger feeding the logger, for libraries accepting one.
+func NewSlogLogger(logger *Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(logger, nil))
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.level == nil {
+		return true
+	}
+	return level >= h.level.Level()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger := h.target()
+	traceId, username := traceIdAndUsername(ctx)
+	contextFields := ContextFields(ctx)
+	fields := make([]Field, 0, len(contextFields)+len(h.fields)+r.NumAttrs())
+	fields = append(fields, contextFields...)
+	fields = append(fields, h.fields...)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, attr)
+		return true
+	})
+	recordTime := r.Time
+	if recordTime.IsZero() {
+		recordTime = time.Now()
+	}
+	logger.Write(&Record{
+		Time:     recordTime.In(logger.location()),
+		Level:    levelFromSlog(r.Level),
+		TraceId:  traceId,
+		Username: username,
+		Message:  r.Message,
+		Fields:   fields,
+		Caller:   callerFromPC(r.PC),
+	})
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.fields = make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(clone.fields, h.fields)
+	for _, attr := range attrs {
+		clone.fields = appendAttr(clone.fields, h.prefix, attr)
+	}
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == constant.EmptyString {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+func (h *SlogHandler) target() *Logger {
+	if h.logger == nil {
+		return Default()
+	}
+	return h.logger
+}
+
+// appendAttr flattens an attribute into fields, keeping the Go type of the value.
+func appendAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != constant.EmptyString {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			fields = appendAttr(fields, groupPrefix, groupAttr)
+		}
+		return fields
+	}
+	return append(fields, Field{Key: prefix + attr.Key, Value: attr.Value.Any()})
+}
+
+// LevelTrace and LevelFatal are the slog levels matching constant.Trace and constant.Fatal.
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+)
+
+// levelFromSlog maps a slog level to the closest constant.LogLevelType.
+func levelFromSlog(level slog.Level) constant.LogLevelType {
+	switch {
+	case level < slog.LevelDebug:
+		return constant.Trace
+	case level < slog.LevelInfo:
+		return constant.Debug
+	case level < slog.LevelWarn:
+		return constant.Info
+	case level < slog.LevelError:
+		return constant.Warn
+	case level < LevelFatal:
+		return constant.Error
+	default:
+		return constant.Fatal
+	}
+}
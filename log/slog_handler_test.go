@@ -0,0 +1,40 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+func TestSlogHandler_Handle(t *testing.T) {
+	sink := &recordingSink{}
+	logger := slog.New(NewSlogHandler(NewLogger(Output{Name: "test", Sink: sink}), slog.LevelInfo))
+
+	ctx := context.WithValue(context.Background(), constant.TraceIdLogKey, "trace")
+	ctx = context.WithValue(ctx, constant.UsernameLogKey, "user")
+	logger.DebugContext(ctx, "filtered out")
+	logger.With("component", "db").WithGroup("query").WarnContext(ctx, "slow query", "rows", 12, slog.Group("plan", "cached", true))
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(sink.records))
+	}
+	record := sink.records[0]
+	if record.Level != constant.Warn || record.Message != "slow query" || record.TraceId != "trace" || record.Username != "user" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	expect := []Field{
+		{Key: "component", Value: "db"},
+		{Key: "query.rows", Value: int64(12)},
+		{Key: "query.plan.cached", Value: true},
+	}
+	if len(record.Fields) != len(expect) {
+		t.Fatalf("Expected fields %v, got %v", expect, record.Fields)
+	}
+	for i := range expect {
+		if record.Fields[i] != expect[i] {
+			t.Errorf("Expected field %v, got %v", expect[i], record.Fields[i])
+		}
+	}
+}
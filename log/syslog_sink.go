@@ -0,0 +1,361 @@
+package log
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+const (
+	SyslogUDP = "udp"
+	SyslogTCP = "tcp"
+	SyslogTLS = "tls"
+
+	// OctetCounting prefixes each frame with its length (RFC 6587 3.4.1), the default over TCP and TLS
+	OctetCounting = "octet-counting"
+	// NonTransparentFraming ends each frame with a line feed (RFC 6587 3.4.2)
+	NonTransparentFraming = "non-transparent"
+
+	// FacilityUser is the "user-level messages" facility
+	FacilityUser = 1
+	// FacilityLocal0 is the first of the facilities reserved for local use
+	FacilityLocal0 = 16
+
+	syslogTimestampFormat          = "2006-01-02T15:04:05.000000Z07:00"
+	defaultSyslogBufferSize        = 1000
+	defaultSyslogReconnectInterval = 2 * time.Second
+	defaultSyslogDialTimeout       = 5 * time.Second
+	defaultSyslogWriteTimeout      = 5 * time.Second
+	syslogErrorBufferSize          = 100
+	// 32473 is the private enterprise number reserved for documentation (RFC 5612)
+	defaultStructuredDataId = "log@32473"
+)
+
+// ErrSyslogBufferFull is reported when a record is dropped because the collector is too slow or unreachable.
+var ErrSyslogBufferFull = errors.New("syslog buffer is full, record dropped")
+
+// SyslogSink sends records as RFC 5424 messages to a syslog collector such as rsyslog.
+//
+// Records are queued and sent from a single goroutine. When the collector is unreachable the sink keeps
+// reconnecting every ReconnectInterval while up to BufferSize records wait in the queue, newer records are dropped.
+// Connection errors and drops are reported on Errors.
+type SyslogSink struct {
+	// Network is "udp", "tcp" or "tls"
+	Network   string
+	Address   string
+	TLSConfig *tls.Config
+	// Framing of the stream transports, OctetCounting by default
+	Framing  string
+	Facility int
+	Hostname string
+	AppName  string
+	ProcId   string
+	// StructuredDataId is the SD-ID holding traceId, username and the fields, "log@32473" by default
+	StructuredDataId  string
+	BufferSize        int
+	ReconnectInterval time.Duration
+	DialTimeout       time.Duration
+	// WriteTimeout bounds each write over TCP and TLS, 5 seconds by default.
+	// A collector that stops reading gets its connection closed, the frame is sent again on a new one.
+	WriteTimeout time.Duration
+
+	once    sync.Once
+	queue   chan []byte
+	errors  chan error
+	dropped atomic.Int64
+	// pending counts the frames queued or being sent
+	pending atomic.Int64
+	// mu guards closed, Write holds it for reading so Close never closes the queue under a pending Write
+	mu         sync.RWMutex
+	closed     bool
+	stop       chan struct{}
+	stopOnce   sync.Once
+	done       chan struct{}
+	conn       net.Conn
+	connClosed <-chan struct{}
+}
+
+// NewSyslogSink creates a syslog sink for the collector at address, the service name of the config is the APP-NAME.
+func NewSyslogSink(network string, address string, config Config) *SyslogSink {
+	hostname, _ := os.Hostname()
+	return &SyslogSink{
+		Network:  network,
+		Address:  address,
+		Facility: FacilityUser,
+		Hostname: hostname,
+		AppName:  config.ServiceName,
+		ProcId:   strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (s *SyslogSink) Write(record *Record) error {
+	s.init()
+	frame := s.frame(record)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	select {
+	case s.queue <- frame:
+		s.pending.Add(1)
+		return nil
+	default:
+		s.dropped.Add(1)
+		return ErrSyslogBufferFull
+	}
+}
+
+// Errors returns the channel of the connection and delivery errors.
+func (s *SyslogSink) Errors() <-chan error {
+	s.init()
+	return s.errors
+}
+
+// Dropped returns the number of records dropped because the buffer was full,
+// or because they were still queued when Close gave up.
+func (s *SyslogSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close stops accepting records and waits until the queued ones are sent or the context ends.
+// The records still queued at the end of the context are dropped.
+func (s *SyslogSink) Close(ctx context.Context) error {
+	s.init()
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.stopOnce.Do(func() {
+			close(s.stop)
+			s.dropped.Add(s.pending.Load())
+		})
+		return ctx.Err()
+	}
+}
+
+func (s *SyslogSink) init() {
+	s.once.Do(func() {
+		if s.BufferSize <= 0 {
+			s.BufferSize = defaultSyslogBufferSize
+		}
+		if s.ReconnectInterval <= 0 {
+			s.ReconnectInterval = defaultSyslogReconnectInterval
+		}
+		if s.DialTimeout <= 0 {
+			s.DialTimeout = defaultSyslogDialTimeout
+		}
+		if s.WriteTimeout <= 0 {
+			s.WriteTimeout = defaultSyslogWriteTimeout
+		}
+		s.queue = make(chan []byte, s.BufferSize)
+		s.errors = make(chan error, syslogErrorBufferSize)
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.listen()
+	})
+}
+
+// listen sends the queued frames one by one, a frame is only given up when Close times out.
+func (s *SyslogSink) listen() {
+	defer func() {
+		if s.conn != nil {
+			_ = s.conn.Close()
+		}
+		close(s.done)
+	}()
+	for frame := range s.queue {
+		for !s.send(frame) {
+			select {
+			case <-s.stop:
+				return
+			case <-time.After(s.ReconnectInterval):
+			}
+		}
+		s.pending.Add(-1)
+	}
+}
+
+// send writes a frame, reconnecting first if needed, and reports whether it was written.
+func (s *SyslogSink) send(frame []byte) bool {
+	if s.conn != nil {
+		select {
+		case <-s.connClosed:
+			_ = s.conn.Close()
+			s.conn = nil
+		default:
+		}
+	}
+	if s.conn == nil {
+		conn, dialError := s.dial()
+		if dialError != nil {
+			s.reportError(dialError)
+			return false
+		}
+		s.conn = conn
+		if s.Network != SyslogUDP {
+			s.connClosed = watchConn(conn)
+		}
+	}
+	if s.Network != SyslogUDP {
+		// a collector that stops reading would block the sink forever
+		if deadlineError := s.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout)); deadlineError != nil {
+			s.reportError(deadlineError)
+			_ = s.conn.Close()
+			s.conn = nil
+			return false
+		}
+	}
+	if _, writeError := s.conn.Write(frame); writeError != nil {
+		s.reportError(writeError)
+		_ = s.conn.Close()
+		s.conn = nil
+		return false
+	}
+	return true
+}
+
+// watchConn closes the returned channel when the collector closes the stream connection, e.g. on restart.
+// Without it the next frame would be accepted by the kernel and lost.
+// Collectors never send data, so the read only returns when the connection is gone.
+func watchConn(conn net.Conn) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(closed)
+	}()
+	return closed
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.DialTimeout}
+	switch s.Network {
+	case SyslogTLS:
+		return tls.DialWithDialer(dialer, "tcp", s.Address, s.TLSConfig)
+	case SyslogUDP, SyslogTCP:
+		return dialer.Dial(s.Network, s.Address)
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", s.Network)
+	}
+}
+
+func (s *SyslogSink) reportError(err error) {
+	select {
+	case s.errors <- err:
+	// Don't block in case no one is listening or our errors channel is full
+	default:
+	}
+}
+
+// frame encodes the record as an RFC 5424 message with the framing of the transport.
+func (s *SyslogSink) frame(record *Record) []byte {
+	facility := s.Facility
+	if facility < 0 || facility > 23 {
+		facility = FacilityUser
+	}
+	structuredDataId := s.StructuredDataId
+	if structuredDataId == constant.EmptyString {
+		structuredDataId = defaultStructuredDataId
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<" + strconv.Itoa(facility*8+syslogSeverity(record.Level)) + ">1 ")
+	sb.WriteString(record.Time.Format(syslogTimestampFormat) + " ")
+	sb.WriteString(syslogHeaderField(s.Hostname, 255) + " ")
+	sb.WriteString(syslogHeaderField(s.AppName, 48) + " ")
+	sb.WriteString(syslogHeaderField(s.ProcId, 128) + " ")
+	sb.WriteString(syslogHeaderField(string(record.Level), 32) + " ")
+
+	sb.WriteString("[" + structuredDataId)
+	writeSyslogParam(&sb, "traceId", record.TraceId)
+	writeSyslogParam(&sb, "username", record.Username)
+	for _, field := range record.Fields {
+		writeSyslogParam(&sb, field.Key, fmt.Sprint(field.Value))
+	}
+	sb.WriteString("] ")
+
+	sb.WriteString(record.Message)
+	if record.Stack != constant.EmptyString {
+		sb.WriteString("\n" + record.Stack)
+	}
+
+	message := sb.String()
+	switch {
+	case s.Network == SyslogUDP:
+		return []byte(message)
+	case s.Framing == NonTransparentFraming:
+		return []byte(strings.ReplaceAll(message, "\n", " ") + "\n")
+	default:
+		return []byte(strconv.Itoa(len(message)) + " " + message)
+	}
+}
+
+// syslogSeverity maps a level to the RFC 5424 severity.
+func syslogSeverity(level constant.LogLevelType) int {
+	switch level {
+	case constant.Fatal:
+		return 2
+	case constant.Error:
+		return 3
+	case constant.Warn:
+		return 4
+	case constant.Info:
+		return 6
+	case constant.Debug, constant.Trace:
+		return 7
+	default:
+		return 6
+	}
+}
+
+// syslogHeaderField keeps the printable US-ASCII characters of a header field, "-" when empty.
+func syslogHeaderField(value string, maxLength int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(cleaned) > maxLength {
+		cleaned = cleaned[:maxLength]
+	}
+	if cleaned == constant.EmptyString {
+		return "-"
+	}
+	return cleaned
+}
+
+// writeSyslogParam writes an SD-PARAM, escaping '"', '\' and ']' in the value.
+func writeSyslogParam(sb *strings.Builder, name string, value string) {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if name == constant.EmptyString {
+		return
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	sb.WriteString(" " + name + `="` + value + `"`)
+}
@@ -0,0 +1,211 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuanloc1105/go-common-lib/constant"
+)
+
+// readOctetCountedFrame reads one "LEN SP MSG" frame.
+func readOctetCountedFrame(reader *bufio.Reader) (string, error) {
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		return "", err
+	}
+	frame := make([]byte, size)
+	_, err = io.ReadFull(reader, frame)
+	return string(frame), err
+}
+
+// acceptFrames collects the frames sent to the listener until the returned function stops the collector.
+func acceptFrames(listener net.Listener, frames chan<- string) (stop func()) {
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					frame, err := readOctetCountedFrame(reader)
+					if err != nil {
+						return
+					}
+					frames <- frame
+				}
+			}(conn)
+		}
+	}()
+	return func() {
+		_ = listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}
+}
+
+func waitFrame(t *testing.T, frames <-chan string) string {
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for a syslog frame")
+		return ""
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	address := listener.Addr().String()
+	frames := make(chan string, 10)
+	stop := acceptFrames(listener, frames)
+
+	sink := NewSyslogSink(SyslogTCP, address, Config{ServiceName: "billing"})
+	sink.Hostname = "pod-1"
+	sink.ProcId = "42"
+	sink.ReconnectInterval = 10 * time.Millisecond
+	record := &Record{
+		Time:     time.Date(2024, time.May, 3, 10, 20, 30, 123456000, time.UTC),
+		Level:    constant.Error,
+		TraceId:  "trace",
+		Username: `lo"c]`,
+		Message:  "payment failed",
+		Fields:   []Field{{Key: "roomId", Value: 7}},
+	}
+	if err := sink.Write(record); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expect := `<11>1 2024-05-03T10:20:30.123456Z pod-1 billing 42 ERROR [log@32473 traceId="trace" username="lo\"c\]" roomId="7"] payment failed`
+	if frame := waitFrame(t, frames); frame != expect {
+		t.Errorf("Expected %q, got %q", expect, frame)
+	}
+
+	// restart the collector, the records written meanwhile are buffered and delivered after reconnecting
+	stop()
+	// give the closing handshake the time to reach the sink
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: "after restart " + strconv.Itoa(i)})
+	}
+	time.Sleep(50 * time.Millisecond)
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("Can not listen again on %s: %s", address, err)
+	}
+	defer acceptFrames(listener, frames)()
+	for i := 0; i < 3; i++ {
+		if frame := waitFrame(t, frames); !strings.HasSuffix(frame, "after restart "+strconv.Itoa(i)) {
+			t.Errorf("Expected the buffered record %d, got %q", i, frame)
+		}
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer conn.Close()
+
+	sink := NewSyslogSink(SyslogUDP, conn.LocalAddr().String(), Config{ServiceName: "billing"})
+	sink.Facility = FacilityLocal0
+	_ = sink.Write(&Record{Time: time.Now(), Level: constant.Debug, Message: "hello"})
+
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pattern := regexp.MustCompile(`^<135>1 \S+ \S+ billing \d+ DEBUG \[log@32473 traceId="" username=""\] hello$`)
+	if !pattern.Match(b[:n]) {
+		t.Errorf("Unexpected datagram %q", b[:n])
+	}
+}
+
+func TestSyslogSink_WriteTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	// the collector accepts the connections but never reads them
+	var mu sync.Mutex
+	var conns []net.Conn
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	go func() {
+		for {
+			conn, acceptError := listener.Accept()
+			if acceptError != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+
+	sink := &SyslogSink{
+		Network:           SyslogTCP,
+		Address:           listener.Addr().String(),
+		BufferSize:        1000,
+		WriteTimeout:      50 * time.Millisecond,
+		ReconnectInterval: 10 * time.Millisecond,
+	}
+	message := strings.Repeat("x", 64*1024)
+	for i := 0; i < 500; i++ {
+		_ = sink.Write(&Record{Time: time.Now(), Level: constant.Info, Message: message})
+	}
+	select {
+	case err := <-sink.Errors():
+		var netError net.Error
+		if !errors.As(err, &netError) || !netError.Timeout() {
+			t.Errorf("Expected a write timeout, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the write to a collector not reading to time out")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := sink.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Close to give up with its context, got %v", err)
+	}
+	select {
+	case <-sink.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the sink goroutine to stop once Close gave up")
+	}
+}
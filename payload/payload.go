@@ -33,3 +33,13 @@ type PageResponse struct {
 	TotalPage    int64  `json:"totalPage"`
 	Response     any    `json:"response"`
 }
+
+type LogLevelRequestBodyValue struct {
+	Output  string `json:"output"`
+	Package string `json:"package"`
+	Level   string `json:"level"`
+}
+
+type LogLevelRequestBody struct {
+	Request LogLevelRequestBodyValue `json:"request"`
+}
@@ -40,6 +40,40 @@ func ErrorHandler(c *gin.Context) {
 	}
 }
 
+// Recovery recovers from panics in the next handlers, logs the panic with its stack trace at ERROR
+// and answers with constant.InternalFailure and HTTP 500. Register it before the other middlewares.
+// http.ErrAbortHandler is panicked again, so that net/http aborts the response as the handler asked.
+func Recovery(c *gin.Context) {
+	CheckAndSetTraceId(c)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			currentUser := "unknown"
+			if claims, isMapClaims := c.Value("auth").(jwt.MapClaims); isMapClaims {
+				if subject, isString := claims["sub"].(string); isString {
+					currentUser = subject
+				}
+			}
+			var ctx = context.Background()
+			ctx = context.WithValue(ctx, constant.UsernameLogKey, currentUser)
+			ctx = context.WithValue(ctx, constant.TraceIdLogKey, GetTraceId(c))
+			log.WithStack(
+				constant.Error,
+				ctx,
+				fmt.Sprintf("Recovered from panic on %s %s: %v", c.Request.Method, c.Request.RequestURI, recovered),
+			)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, &payload.Response{
+				Trace:        GetTraceId(c),
+				ErrorCode:    constant.InternalFailure.ErrorCode,
+				ErrorMessage: constant.InternalFailure.ErrorMessage,
+			})
+		}
+	}()
+	c.Next()
+}
+
 func RequestLogger(c *gin.Context) {
 	CheckAndSetTraceId(c)
 	// t := time.Now()
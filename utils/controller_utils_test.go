@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
+	"github.com/tuanloc1105/go-common-lib/payload"
+)
+
+// recoveryRouter answers /panic with a panic of value, after the authentication set the claims
+func recoveryRouter(value any) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery)
+	router.GET("/panic", func(c *gin.Context) {
+		c.Set("auth", jwt.MapClaims{"sub": "alice"})
+		panic(value)
+	})
+	return router
+}
+
+func TestRecovery(t *testing.T) {
+	previous := log.Default()
+	defer log.SetDefault(previous)
+	sink := log.NewRingBufferSink(10)
+	log.SetDefault(log.NewLogger(log.Output{Name: "test", Sink: sink}))
+
+	recorder := httptest.NewRecorder()
+	recoveryRouter("boom").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected HTTP 500, got %d", recorder.Code)
+	}
+	var response payload.Response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode the response %q: %v", recorder.Body.String(), err)
+	}
+	if response.Trace == "" || response.ErrorCode != constant.InternalFailure.ErrorCode ||
+		response.ErrorMessage != constant.InternalFailure.ErrorMessage {
+		t.Errorf("Expected the InternalFailure response, got %+v", response)
+	}
+
+	records := sink.Records(log.RecordFilter{Level: constant.Error})
+	if len(records) != 1 {
+		t.Fatalf("Expected the panic to be logged once, got %d records", len(records))
+	}
+	record := records[0]
+	if record.TraceId != response.Trace || record.Username != "alice" {
+		t.Errorf("Expected the traceId %q and the username alice, got %q and %q", response.Trace, record.TraceId, record.Username)
+	}
+	if !strings.Contains(record.Message, "GET /panic: boom") || record.Stack == "" {
+		t.Errorf("Expected the panic with its stack trace, got %q", record.Message)
+	}
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be panicked again, got %v", recovered)
+		}
+	}()
+	recoveryRouter(http.ErrAbortHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+}
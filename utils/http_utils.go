@@ -10,8 +10,8 @@ import (
 	"slices"
 	"strings"
 
-	"github.com/charmbracelet/log"
 	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
 )
 
 func ConsumeApi(
@@ -22,17 +22,6 @@ func ConsumeApi(
 	payload string,
 	isVerifySsl bool,
 ) (string, error) {
-	usernameFromContext := ctx.Value(constant.UsernameLogKey)
-	traceIdFromContext := ctx.Value(constant.TraceIdLogKey)
-	username := ""
-	traceId := ""
-	if usernameFromContext != nil {
-		username = usernameFromContext.(string)
-	}
-	if traceIdFromContext != nil {
-		traceId = traceIdFromContext.(string)
-	}
-
 	if !slices.Contains(constant.ValidMethod, method) {
 		return "", errors.New("invalid method")
 	}
@@ -46,24 +35,18 @@ func ConsumeApi(
 	}
 	req, err := http.NewRequest(method, url, strings.NewReader(payload))
 	if err != nil {
-		log.Error(
-			fmt.Sprintf(
-				constant.LogPattern,
-				traceId,
-				username,
-				"ConsumeApi - http.NewRequest - error: "+err.Error(),
-			),
+		log.WithLevel(
+			constant.Error,
+			ctx,
+			"ConsumeApi - http.NewRequest - error: "+err.Error(),
 		)
 		return "", err
 	}
 
-	log.Info(
-		fmt.Sprintf(
-			constant.LogPattern,
-			traceId,
-			username,
-			curlBuilder(url, payload, header),
-		),
+	log.WithLevel(
+		constant.Info,
+		ctx,
+		curlBuilder(url, payload, header),
 	)
 
 	for k, v := range header {
@@ -71,13 +54,10 @@ func ConsumeApi(
 	}
 	res, err := client.Do(req)
 	if err != nil {
-		log.Error(
-			fmt.Sprintf(
-				constant.LogPattern,
-				traceId,
-				username,
-				"ConsumeApi - client.Do - error: "+err.Error(),
-			),
+		log.WithLevel(
+			constant.Error,
+			ctx,
+			"ConsumeApi - client.Do - error: "+err.Error(),
 		)
 		return "", err
 	}
@@ -102,28 +82,22 @@ func ConsumeApi(
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Error(
-			fmt.Sprintf(
-				constant.LogPattern,
-				traceId,
-				username,
-				"ConsumeApi - io.ReadAll - error: "+err.Error(),
-			),
+		log.WithLevel(
+			constant.Error,
+			ctx,
+			"ConsumeApi - io.ReadAll - error: "+err.Error(),
 		)
 		return "", err
 	}
 	result := string(body)
-	log.Info(
+	log.WithLevel(
+		constant.Info,
+		ctx,
 		fmt.Sprintf(
-			constant.LogPattern,
-			traceId,
-			username,
-			fmt.Sprintf(
-				"\t- status: %s\n\t- header: %s\n\t- payload: %s",
-				res.Status,
-				headerString,
-				result,
-			),
+			"\t- status: %s\n\t- header: %s\n\t- payload: %s",
+			res.Status,
+			headerString,
+			result,
 		),
 	)
 
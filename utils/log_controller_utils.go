@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
+	"github.com/tuanloc1105/go-common-lib/payload"
+)
+
+// GetLogLevelHandler returns the output and package levels of the logger, a nil logger uses log.Default.
+func GetLogLevelHandler(logger *log.Logger) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		CheckAndSetTraceId(c)
+		c.JSON(http.StatusOK, ReturnResponse(c, constant.Success, logLevelTarget(logger).Levels()))
+	}
+}
+
+// SetLogLevelHandler changes the levels of the logger at runtime, a nil logger uses log.Default.
+// The request body is a payload.LogLevelRequestBody:
+//   - with a package, the level applies to the records of that package, an empty level removes it
+//   - with an output, the level applies to that output
+//   - with neither, the level applies to every output
+//
+// Mount it behind AuthenticationWithAuthorization, e.g.
+//
+//	router.PUT("/admin/log-level", utils.AuthenticationWithAuthorization([]string{"ADMIN"}), utils.SetLogLevelHandler(nil))
+func SetLogLevelHandler(logger *log.Logger) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		CheckAndSetTraceId(c)
+		target := logLevelTarget(logger)
+		requestBody := payload.LogLevelRequestBody{}
+		if shouldBindJsonError := c.ShouldBindJSON(&requestBody); shouldBindJsonError != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ReturnResponse(c, constant.JsonBindingError, nil, shouldBindJsonError.Error()))
+			return
+		}
+		request := requestBody.Request
+
+		level := constant.LogLevelType(constant.EmptyString)
+		if request.Level != constant.EmptyString || request.Package == constant.EmptyString {
+			parsedLevel, parseLevelError := log.ParseLevel(request.Level)
+			if parseLevelError != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, ReturnResponse(c, constant.DataFormatError, nil, parseLevelError.Error()))
+				return
+			}
+			level = parsedLevel
+		}
+
+		switch {
+		case request.Package != constant.EmptyString:
+			target.SetPackageLevel(request.Package, level)
+		case request.Output != constant.EmptyString:
+			if setOutputLevelError := target.SetOutputLevel(request.Output, level); setOutputLevelError != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, ReturnResponse(c, constant.DataFormatError, nil, setOutputLevelError.Error()))
+				return
+			}
+		default:
+			for _, output := range target.Outputs() {
+				_ = target.SetOutputLevel(output.Name, level)
+			}
+		}
+
+		ctx, _ := PrepareContext(c, true)
+		log.WithLevel(
+			constant.Warn,
+			ctx,
+			fmt.Sprintf("Log level changed:\n\t- output: %s\n\t- package: %s\n\t- level: %s", request.Output, request.Package, level),
+		)
+		c.JSON(http.StatusOK, ReturnResponse(c, constant.Success, target.Levels()))
+	}
+}
+
+// RecentLogHandler serves the records kept by a log.RingBufferSink, a nil sink uses the memory output of log.Default.
+// The records can be filtered with the query parameters level (minimum level), traceId, username,
+// from and to (RFC 3339) and limit (most recent records only).
+func RecentLogHandler(sink *log.RingBufferSink) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		CheckAndSetTraceId(c)
+		target := sink
+		if target == nil {
+			target = log.Default().MemorySink()
+		}
+		if target == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, ReturnResponse(c, constant.PageNotFound, nil, "the logger does not keep records in memory"))
+			return
+		}
+
+		filter := log.RecordFilter{
+			TraceId:  c.Query("traceId"),
+			Username: c.Query("username"),
+		}
+		var parseError error
+		if levelName := c.Query("level"); levelName != constant.EmptyString {
+			filter.Level, parseError = log.ParseLevel(levelName)
+		}
+		if from := c.Query("from"); from != constant.EmptyString && parseError == nil {
+			filter.From, parseError = time.Parse(time.RFC3339, from)
+		}
+		if to := c.Query("to"); to != constant.EmptyString && parseError == nil {
+			filter.To, parseError = time.Parse(time.RFC3339, to)
+		}
+		if limit := c.Query("limit"); limit != constant.EmptyString && parseError == nil {
+			filter.Limit, parseError = strconv.Atoi(limit)
+		}
+		if parseError != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ReturnResponse(c, constant.DataFormatError, nil, parseError.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, ReturnResponse(c, constant.Success, target.Records(filter)))
+	}
+}
+
+// MountRecentLogEndpoint registers RecentLogHandler on the router behind AuthenticationWithAuthorization, e.g.
+//
+//	utils.MountRecentLogEndpoint(router, "/admin/logs", nil, []string{"ADMIN"})
+func MountRecentLogEndpoint(router gin.IRoutes, path string, sink *log.RingBufferSink, listOfRole []string) {
+	router.GET(path, AuthenticationWithAuthorization(listOfRole), RecentLogHandler(sink))
+}
+
+func logLevelTarget(logger *log.Logger) *log.Logger {
+	if logger == nil {
+		return log.Default()
+	}
+	return logger
+}
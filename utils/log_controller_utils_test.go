@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tuanloc1105/go-common-lib/constant"
+	"github.com/tuanloc1105/go-common-lib/log"
+)
+
+// discardSink drops the records of the loggers under test
+type discardSink struct{}
+
+func (discardSink) Write(*log.Record) error {
+	return nil
+}
+
+// useDefaultLogger replaces log.Default for the test
+func useDefaultLogger(t *testing.T, logger *log.Logger) {
+	previous := log.Default()
+	log.SetDefault(logger)
+	t.Cleanup(func() {
+		log.SetDefault(previous)
+	})
+}
+
+// serve sends the request to the handler and decodes the response into response
+func serve(t *testing.T, router *gin.Engine, request *http.Request, response any) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatalf("Failed to decode the response %q: %v", recorder.Body.String(), err)
+	}
+	return recorder.Code
+}
+
+type levelsResponse struct {
+	ErrorCode int               `json:"errorCode"`
+	Response  log.LevelSettings `json:"response"`
+}
+
+func newLevelRouter(t *testing.T) (*gin.Engine, *log.Logger) {
+	gin.SetMode(gin.TestMode)
+	logger := log.NewLogger(
+		log.Output{Name: log.ConsoleOutputName, Level: constant.Info, Sink: discardSink{}},
+		log.Output{Name: log.FileOutputName, Level: constant.Info, Sink: discardSink{}},
+	)
+	useDefaultLogger(t, log.NewLogger())
+	router := gin.New()
+	router.GET("/log-level", GetLogLevelHandler(logger))
+	router.PUT("/log-level", SetLogLevelHandler(logger))
+	return router, logger
+}
+
+func putLevel(t *testing.T, router *gin.Engine, output string, packagePath string, level string) (int, levelsResponse) {
+	body, _ := json.Marshal(map[string]any{"request": map[string]string{"output": output, "package": packagePath, "level": level}})
+	var response levelsResponse
+	code := serve(t, router, httptest.NewRequest(http.MethodPut, "/log-level", bytes.NewReader(body)), &response)
+	return code, response
+}
+
+func TestSetLogLevelHandler_Output(t *testing.T) {
+	router, logger := newLevelRouter(t)
+	code, response := putLevel(t, router, log.FileOutputName, "", "debug")
+	if code != http.StatusOK || response.ErrorCode != constant.Success.ErrorCode {
+		t.Fatalf("Expected the level to be set, got %d %+v", code, response)
+	}
+	levels := logger.Levels()
+	if levels.Outputs[log.FileOutputName] != constant.Debug || levels.Outputs[log.ConsoleOutputName] != constant.Info {
+		t.Errorf("Expected only the file output to change, got %v", levels.Outputs)
+	}
+	if response.Response.Outputs[log.FileOutputName] != constant.Debug {
+		t.Errorf("Expected the new levels in the response, got %+v", response.Response)
+	}
+}
+
+func TestSetLogLevelHandler_Package(t *testing.T) {
+	router, logger := newLevelRouter(t)
+	const packagePath = "github.com/tuanloc1105/go-common-lib/audit"
+	if code, response := putLevel(t, router, "", packagePath, "TRACE"); code != http.StatusOK {
+		t.Fatalf("Expected the package level to be set, got %d %+v", code, response)
+	}
+	if level := logger.Levels().Packages[packagePath]; level != constant.Trace {
+		t.Errorf("Expected the TRACE package level, got %q", level)
+	}
+	// an empty level removes it
+	if code, response := putLevel(t, router, "", packagePath, ""); code != http.StatusOK {
+		t.Fatalf("Expected the package level to be removed, got %d %+v", code, response)
+	}
+	if _, isSet := logger.Levels().Packages[packagePath]; isSet {
+		t.Errorf("Expected no package level, got %v", logger.Levels().Packages)
+	}
+}
+
+func TestSetLogLevelHandler_AllOutputs(t *testing.T) {
+	router, logger := newLevelRouter(t)
+	if code, response := putLevel(t, router, "", "", "ERROR"); code != http.StatusOK {
+		t.Fatalf("Expected the level to be set, got %d %+v", code, response)
+	}
+	for name, level := range logger.Levels().Outputs {
+		if level != constant.Error {
+			t.Errorf("Expected ERROR on the %s output, got %q", name, level)
+		}
+	}
+}
+
+func TestSetLogLevelHandler_BadRequest(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		level  string
+	}{
+		{name: "Bad level", level: "LOUD"},
+		{name: "Unknown output", output: "nowhere", level: "INFO"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			router, logger := newLevelRouter(t)
+			code, response := putLevel(t, router, testCase.output, "", testCase.level)
+			if code != http.StatusBadRequest || response.ErrorCode != constant.DataFormatError.ErrorCode {
+				t.Errorf("Expected a 400 DataFormatError, got %d %+v", code, response)
+			}
+			for name, level := range logger.Levels().Outputs {
+				if level != constant.Info {
+					t.Errorf("Expected the %s output to keep its level, got %q", name, level)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLogLevelHandler(t *testing.T) {
+	router, logger := newLevelRouter(t)
+	logger.SetPackageLevel("github.com/tuanloc1105/go-common-lib/audit", constant.Warn)
+	var response levelsResponse
+	code := serve(t, router, httptest.NewRequest(http.MethodGet, "/log-level", nil), &response)
+	if code != http.StatusOK || response.ErrorCode != constant.Success.ErrorCode {
+		t.Fatalf("Expected the levels, got %d %+v", code, response)
+	}
+	if len(response.Response.Outputs) != 2 || response.Response.Outputs[log.ConsoleOutputName] != constant.Info ||
+		response.Response.Packages["github.com/tuanloc1105/go-common-lib/audit"] != constant.Warn {
+		t.Errorf("Unexpected levels %+v", response.Response)
+	}
+}
+
+type recordsResponse struct {
+	ErrorCode int          `json:"errorCode"`
+	Response  []log.Record `json:"response"`
+}
+
+func newRecentLogRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	sink := log.NewRingBufferSink(10)
+	start := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	for i, record := range []log.Record{
+		{Level: constant.Debug, TraceId: "trace-1", Username: "alice", Message: "debug"},
+		{Level: constant.Info, TraceId: "trace-1", Username: "alice", Message: "info"},
+		{Level: constant.Warn, TraceId: "trace-2", Username: "bob", Message: "warn"},
+		{Level: constant.Error, TraceId: "trace-2", Username: "alice", Message: "error"},
+	} {
+		record.Time = start.Add(time.Duration(i) * time.Minute)
+		_ = sink.Write(&record)
+	}
+	router := gin.New()
+	router.GET("/logs", RecentLogHandler(sink))
+	return router
+}
+
+func TestRecentLogHandler_Filters(t *testing.T) {
+	router := newRecentLogRouter(t)
+	testCases := []struct {
+		name   string
+		query  string
+		expect []string
+	}{
+		{name: "No filter", query: "", expect: []string{"debug", "info", "warn", "error"}},
+		{name: "Level", query: "level=warn", expect: []string{"warn", "error"}},
+		{name: "TraceId", query: "traceId=trace-1", expect: []string{"debug", "info"}},
+		{name: "Username", query: "username=bob", expect: []string{"warn"}},
+		{name: "From", query: "from=2024-05-03T10:02:00Z", expect: []string{"warn", "error"}},
+		{name: "To", query: "to=2024-05-03T10:01:00Z", expect: []string{"debug", "info"}},
+		{name: "Limit", query: "limit=1", expect: []string{"error"}},
+		{name: "Combined", query: "username=alice&level=INFO&limit=5", expect: []string{"info", "error"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var response recordsResponse
+			code := serve(t, router, httptest.NewRequest(http.MethodGet, "/logs?"+testCase.query, nil), &response)
+			if code != http.StatusOK {
+				t.Fatalf("Expected the records, got %d %+v", code, response)
+			}
+			var messages []string
+			for _, record := range response.Response {
+				messages = append(messages, record.Message)
+			}
+			if len(messages) != len(testCase.expect) {
+				t.Fatalf("Expected %q, got %q", testCase.expect, messages)
+			}
+			for i := range messages {
+				if messages[i] != testCase.expect[i] {
+					t.Errorf("Expected %q, got %q", testCase.expect, messages)
+				}
+			}
+		})
+	}
+}
+
+func TestRecentLogHandler_BadQuery(t *testing.T) {
+	router := newRecentLogRouter(t)
+	for _, query := range []string{"level=LOUD", "from=yesterday", "to=2024-05-03", "limit=ten"} {
+		var response recordsResponse
+		code := serve(t, router, httptest.NewRequest(http.MethodGet, "/logs?"+query, nil), &response)
+		if code != http.StatusBadRequest || response.ErrorCode != constant.DataFormatError.ErrorCode {
+			t.Errorf("Expected a 400 DataFormatError for %q, got %d %+v", query, code, response)
+		}
+	}
+}
+
+func TestRecentLogHandler_NoMemorySink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDefaultLogger(t, log.NewLogger(log.Output{Name: log.ConsoleOutputName, Sink: discardSink{}}))
+	router := gin.New()
+	router.GET("/logs", RecentLogHandler(nil))
+	var response recordsResponse
+	code := serve(t, router, httptest.NewRequest(http.MethodGet, "/logs", nil), &response)
+	if code != http.StatusNotFound || response.ErrorCode != constant.PageNotFound.ErrorCode {
+		t.Errorf("Expected a 404 without a memory sink, got %d %+v", code, response)
+	}
+}
+
+func TestMountRecentLogEndpoint_Unauthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useDefaultLogger(t, log.NewLogger())
+	sink := log.NewRingBufferSink(10)
+	_ = sink.Write(&log.Record{Time: time.Now(), Level: constant.Info, Message: "secret business"})
+	router := gin.New()
+	MountRecentLogEndpoint(router, "/admin/logs", sink, []string{"ADMIN"})
+	for _, authorization := range []string{"", "Bearer not.a.jwt"} {
+		request := httptest.NewRequest(http.MethodGet, "/admin/logs", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		var response recordsResponse
+		code := serve(t, router, request, &response)
+		if code != http.StatusUnauthorized || response.ErrorCode != constant.Unauthorized.ErrorCode || len(response.Response) != 0 {
+			t.Errorf("Expected a 401 without records for %q, got %d %+v", authorization, code, response)
+		}
+	}
+}
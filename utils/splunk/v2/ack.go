@@ -0,0 +1,215 @@
+package splunk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAckPollInterval = time.Second
+	defaultAckTimeout      = time.Minute
+	defaultAckBatchSize    = 100
+	ackPath                = collectorPath + "/ack"
+)
+
+// ErrAckTimeout is returned when Splunk did not acknowledge the events in time, after every resend
+var ErrAckTimeout = errors.New("splunk did not acknowledge the events in time")
+
+// ErrAckIdMissing is returned when Splunk accepted the events without an ackId,
+// which means indexer acknowledgement is not enabled for the token
+var ErrAckIdMissing = errors.New("splunk returned no ackId, indexer acknowledgement is disabled for the token")
+
+// AckOptions configures the indexer acknowledgement of a Client.
+// With it, LogEvents only returns once Splunk has written the events to an index,
+// so a crashed indexer can't lose events the Client reported as sent.
+// https://docs.splunk.com/Documentation/Splunk/latest/Data/AboutHECIDXAck
+type AckOptions struct {
+	// URL of the ack endpoint, derived from Client.URL by default (i.e. https://{your-splunk-URL}:8088/services/collector/ack)
+	URL string
+	// How often the status of the pending acks is requested, 1 second by default
+	PollInterval time.Duration
+	// How long to wait for the acknowledgement of a request before sending it again, 1 minute by default
+	Timeout time.Duration
+	// How many times a request is sent again when its acknowledgement times out, before giving up with ErrAckTimeout
+	MaxResends int
+	// Max number of ackIds per status request, 100 by default
+	BatchSize int
+}
+
+// ackResponse is the payload returned by the ack endpoint
+type ackResponse struct {
+	Acks map[string]bool `json:"acks"`
+}
+
+// ackPoller polls the status of the pending acks of a Client.
+// Its goroutine only runs while some requests wait for their acknowledgement.
+type ackPoller struct {
+	mu      sync.Mutex
+	waiting map[int]chan error
+	running bool
+}
+
+// sendWithAck sends the body to the endpoint and waits until Splunk acknowledges it, sending it again when it times out
+func (c *Client) sendWithAck(url string, contentType string, body []byte) error {
+	timeout := c.Ack.Timeout
+	if timeout <= 0 {
+		timeout = defaultAckTimeout
+	}
+	for resends := 0; ; resends++ {
+		res, err := c.doRequest(url, contentType, bytes.NewBuffer(body))
+		if err != nil {
+			return err
+		}
+		if res.AckID == nil {
+			return ErrAckIdMissing
+		}
+		acked := c.acks.wait(c, *res.AckID)
+		timer := time.NewTimer(timeout)
+		select {
+		case err = <-acked:
+			timer.Stop()
+			return err
+		case <-timer.C:
+			c.acks.forget(*res.AckID)
+		}
+		if resends >= c.Ack.MaxResends {
+			return ErrAckTimeout
+		}
+	}
+}
+
+// channel returns the channel GUID, generating it on the first call
+func (c *Client) channel() string {
+	c.channelOnce.Do(func() {
+		if c.Channel == "" {
+			c.Channel = uuid.NewString()
+		}
+	})
+	return c.Channel
+}
+
+// ackURL returns the URL of the ack endpoint
+func (c *Client) ackURL() string {
+	if c.Ack.URL != "" {
+		return c.Ack.URL
+	}
+	return c.collectorURL(ackPath)
+}
+
+// collectorURL returns the URL of another Event Collector endpoint of the server of Client.URL
+func (c *Client) collectorURL(path string) string {
+	if i := strings.Index(c.URL, collectorPath); i >= 0 {
+		return c.URL[:i] + path
+	}
+	return strings.TrimSuffix(c.URL, "/") + path
+}
+
+// queryAcks requests the status of the ackIds, returning the acknowledged ones
+func (c *Client) queryAcks(ids []int) ([]int, error) {
+	b, err := json.Marshal(map[string][]int{"acks": ids})
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := c.post(c.ackURL(), jsonContentType, bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	res := &ackResponse{}
+	if err = json.Unmarshal(respBody, res); err != nil {
+		return nil, err
+	}
+	acked := make([]int, 0, len(ids))
+	for id, isAcked := range res.Acks {
+		if n, convertError := strconv.Atoi(id); convertError == nil && isAcked {
+			acked = append(acked, n)
+		}
+	}
+	return acked, nil
+}
+
+// wait registers the ackId and returns a channel receiving nil once it is acknowledged,
+// or the error of the status request
+func (p *ackPoller) wait(c *Client, id int) <-chan error {
+	acked := make(chan error, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waiting == nil {
+		p.waiting = make(map[int]chan error)
+	}
+	p.waiting[id] = acked
+	if !p.running {
+		p.running = true
+		go p.poll(c)
+	}
+	return acked
+}
+
+// forget stops waiting for the ackId
+func (p *ackPoller) forget(id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.waiting, id)
+}
+
+// poll requests the status of the pending acks every PollInterval, and stops once none is left
+func (p *ackPoller) poll(c *Client) {
+	interval := c.Ack.PollInterval
+	if interval <= 0 {
+		interval = defaultAckPollInterval
+	}
+	batchSize := c.Ack.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultAckBatchSize
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ids := p.pending()
+		if len(ids) == 0 {
+			return
+		}
+		for start := 0; start < len(ids); start += batchSize {
+			chunk := ids[start:min(start+batchSize, len(ids))]
+			acked, err := c.queryAcks(chunk)
+			if err != nil {
+				// e.g. ACKDisabled, the requests waiting for these acks fail with it
+				p.resolve(chunk, err)
+				continue
+			}
+			p.resolve(acked, nil)
+		}
+	}
+}
+
+// pending returns the ackIds waiting for their acknowledgement, and marks the poller stopped when there is none
+func (p *ackPoller) pending() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]int, 0, len(p.waiting))
+	for id := range p.waiting {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		p.running = false
+	}
+	return ids
+}
+
+// resolve hands the result to the requests waiting for the ackIds
+func (p *ackPoller) resolve(ids []int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ids {
+		if acked, isWaiting := p.waiting[id]; isWaiting {
+			acked <- err
+			delete(p.waiting, id)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ackServer is an HTTP Event Collector stand in, acknowledging an ackId once it was polled ackAfter times
+type ackServer struct {
+	mu       sync.Mutex
+	ackAfter int
+	nextId   int
+	polls    map[int]int
+	channels map[string]bool
+	sends    int
+}
+
+func (s *ackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[r.Header.Get("X-Splunk-Request-Channel")] = true
+	switch r.URL.Path {
+	case "/services/collector/event", "/services/collector/raw":
+		s.sends++
+		fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%d}`, s.nextId)
+		s.nextId++
+	case "/services/collector/ack":
+		var request struct {
+			Acks []int `json:"acks"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		acks := make(map[string]bool)
+		for _, id := range request.Acks {
+			s.polls[id]++
+			acks[strconv.Itoa(id)] = s.ackAfter >= 0 && s.polls[id] > s.ackAfter
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"acks": acks})
+	}
+}
+
+// counts returns the number of sends and whether the channel was used
+func (s *ackServer) counts(channel string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sends, s.channels[channel] && len(s.channels) == 1
+}
+
+func newAckServer(ackAfter int) (*ackServer, *httptest.Server) {
+	s := &ackServer{ackAfter: ackAfter, polls: make(map[int]int), channels: make(map[string]bool)}
+	return s, httptest.NewServer(s)
+}
+
+func TestClient_LogEventsAck(t *testing.T) {
+	s, server := newAckServer(1)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector/event", "token", "", "", "")
+	client.Ack = &AckOptions{PollInterval: time.Millisecond}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- client.Log("event")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected the events to be acknowledged, got %v", err)
+		}
+	}
+	sends, onChannel := s.counts(client.Channel)
+	if client.Channel == "" || !onChannel {
+		t.Errorf("Expected every request on the generated channel %q", client.Channel)
+	}
+	if sends != 5 {
+		t.Errorf("Expected 5 sends without resending, got %d", sends)
+	}
+}
+
+func TestWriter_Ack(t *testing.T) {
+	s, server := newAckServer(1)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector/event", "token", "", "", "")
+	client.Ack = &AckOptions{PollInterval: time.Millisecond}
+	// both writers share the client, their batches are sent concurrently
+	events := &Writer{Client: client, FlushThreshold: 1, FlushInterval: time.Minute}
+	raw := &Writer{Client: client, FlushThreshold: 1, FlushInterval: time.Minute, Raw: true}
+	for i := 0; i < 10; i++ {
+		_, _ = events.Write([]byte(`"event"`))
+		_, _ = raw.Write([]byte("line"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, writer := range []*Writer{events, raw} {
+		if err := writer.Close(ctx); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		select {
+		case err := <-writer.Errors():
+			t.Errorf("Expected the batches to be acknowledged, got %v", err)
+		default:
+		}
+		if writer.Dropped() != 0 {
+			t.Errorf("Expected no message to be dropped, got %d", writer.Dropped())
+		}
+	}
+	sends, onChannel := s.counts(client.Channel)
+	if client.Channel == "" || !onChannel {
+		t.Errorf("Expected every request on the generated channel %q", client.Channel)
+	}
+	if sends < 2 {
+		t.Errorf("Expected the batches of both writers to be sent, got %d sends", sends)
+	}
+}
+
+func TestClient_LogEventsAckTimeout(t *testing.T) {
+	s, server := newAckServer(-1)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector/event", "token", "", "", "")
+	client.Channel = "channel"
+	client.Ack = &AckOptions{PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond, MaxResends: 2}
+
+	if err := client.Log("event"); !errors.Is(err, ErrAckTimeout) {
+		t.Fatalf("Expected ErrAckTimeout, got %v", err)
+	}
+	sends, onChannel := s.counts("channel")
+	if sends != 3 {
+		t.Errorf("Expected the event to be sent 3 times, got %d", sends)
+	}
+	if !onChannel {
+		t.Errorf("Expected the configured channel")
+	}
+}
+
+func TestClient_LogEventsAckDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/services/collector/ack" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"text":"ACK is disabled","code":14}`)
+			return
+		}
+		fmt.Fprint(w, `{"text":"Success","code":0,"ackId":0}`)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector", "token", "", "", "")
+	client.Ack = &AckOptions{PollInterval: time.Millisecond}
+
+	var hecResp *EventCollectorResponse
+	if err := client.Log("event"); !errors.As(err, &hecResp) || hecResp.Code != ACKDisabled {
+		t.Errorf("Expected ACKDisabled, got %v", err)
+	}
+}
+
+func TestClient_AckURL(t *testing.T) {
+	for url, expected := range map[string]string{
+		"https://splunk:8088/services/collector":           "https://splunk:8088/services/collector/ack",
+		"https://splunk:8088/services/collector/event":     "https://splunk:8088/services/collector/ack",
+		"https://splunk:8088/services/collector/event/1.0": "https://splunk:8088/services/collector/ack",
+		"https://splunk:8088/":                             "https://splunk:8088/services/collector/ack",
+	} {
+		client := NewClient(nil, url, "token", "", "", "")
+		client.Ack = &AckOptions{}
+		if actual := client.ackURL(); actual != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, url, actual)
+		}
+	}
+}
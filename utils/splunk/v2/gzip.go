@@ -0,0 +1,68 @@
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+var (
+	// bufferPool holds the buffers of the compressed request bodies
+	bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	// gzipWriterPools holds the gzip writers of every level, from gzip.HuffmanOnly to gzip.BestCompression
+	gzipWriterPools [gzip.BestCompression - gzip.HuffmanOnly + 1]sync.Pool
+)
+
+// gzipLevel returns the compression level of the Client, gzip.DefaultCompression when unset or invalid
+func (c *Client) gzipLevel() int {
+	if c.GzipLevel == gzip.NoCompression || c.GzipLevel < gzip.HuffmanOnly || c.GzipLevel > gzip.BestCompression {
+		return gzip.DefaultCompression
+	}
+	return c.GzipLevel
+}
+
+// compress returns the gzip compressed data, in a pooled buffer released when the body is closed
+func (c *Client) compress(data []byte) (*pooledBody, error) {
+	level := c.gzipLevel()
+	pool := &gzipWriterPools[level-gzip.HuffmanOnly]
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	zw, isPooled := pool.Get().(*gzip.Writer)
+	if isPooled {
+		zw.Reset(buf)
+	} else {
+		var err error
+		if zw, err = gzip.NewWriterLevel(buf, level); err != nil {
+			bufferPool.Put(buf)
+			return nil, err
+		}
+	}
+	_, err := zw.Write(data)
+	if err == nil {
+		err = zw.Close()
+	}
+	pool.Put(zw)
+	if err != nil {
+		bufferPool.Put(buf)
+		return nil, err
+	}
+	return &pooledBody{Reader: bytes.NewReader(buf.Bytes()), buf: buf}, nil
+}
+
+// pooledBody is a request body giving its buffer back to the pool once the transport closes it,
+// the transport may still read the body after the response is received
+type pooledBody struct {
+	*bytes.Reader
+	buf  *bytes.Buffer
+	once sync.Once
+}
+
+var _ io.ReadCloser = (*pooledBody)(nil)
+
+func (b *pooledBody) Close() error {
+	b.once.Do(func() {
+		bufferPool.Put(b.buf)
+	})
+	return nil
+}
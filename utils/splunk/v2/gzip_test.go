@@ -0,0 +1,122 @@
+package splunk
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_LogEventsGzip(t *testing.T) {
+	bodies := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected a gzip body, got %q", r.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read the gzip body: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		bodies <- string(b)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL, "token", "", "", "")
+	client.Gzip = true
+	client.GzipLevel = gzip.BestSpeed
+
+	for _, event := range []string{"first", "second"} {
+		if err := client.Log(event); err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if body := <-bodies; !strings.Contains(body, fmt.Sprintf(`"event":%q`, event)) {
+			t.Errorf("Expected the %s event, got %s", event, body)
+		}
+	}
+}
+
+// benchmarkLogEvents sends batches of similar events, reporting the bytes sent per batch
+func benchmarkLogEvents(b *testing.B, gzipped bool) {
+	var sent atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		sent.Add(n)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL, "token", "app", "_json", "main")
+	client.Gzip = gzipped
+	events := make([]*Event, 100)
+	for i := range events {
+		events[i] = client.NewEventWithTime(time.Now(), map[string]interface{}{
+			"level": "INFO", "traceId": fmt.Sprintf("trace-%d", i), "message": "room joined", "service": "billing",
+		}, client.Source, client.SourceType, client.Index)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.LogEvents(events); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(sent.Load())/float64(b.N), "sent-bytes/op")
+}
+
+func BenchmarkClient_LogEvents(b *testing.B) {
+	benchmarkLogEvents(b, false)
+}
+
+func BenchmarkClient_LogEventsGzip(b *testing.B) {
+	benchmarkLogEvents(b, true)
+}
+
+func TestClient_Authorization(t *testing.T) {
+	var mu sync.Mutex
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.URL.Path+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.URL.Path == "/services/collector/ack" {
+			fmt.Fprint(w, `{"acks":{"0":true}}`)
+			return
+		}
+		fmt.Fprint(w, `{"text":"Success","code":0,"ackId":0}`)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector/event", "secret", "", "", "")
+	if err := client.Log("plain"); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	client.Gzip = true
+	if err := client.Log("gzip"); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if err := client.LogRaw([]byte("raw\n")); err != nil {
+		t.Fatalf("LogRaw failed: %v", err)
+	}
+	acked := NewClient(server.Client(), server.URL+"/services/collector/event", "secret", "", "", "")
+	acked.Ack = &AckOptions{PollInterval: time.Millisecond}
+	if err := acked.Log("acked"); err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"/services/collector/event Splunk secret",
+		"/services/collector/event Splunk secret",
+		"/services/collector/raw Splunk secret",
+		"/services/collector/event Splunk secret",
+		"/services/collector/ack Splunk secret",
+	}
+	if strings.Join(authorizations, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the token on every request, got %q", authorizations)
+	}
+}
@@ -0,0 +1,134 @@
+package splunk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const defaultMaxBatchBytes = 1 << 20
+
+// eventSeparator separates the events of a request body, JSON never holds it as it escapes line breaks
+var eventSeparator = []byte("\r\n\r\n")
+
+// InvalidEventError is returned for an event Splunk rejected with InvalidDataFormat, the other events of its batch being delivered.
+// Writer reports it on Errors and drops the event.
+type InvalidEventError struct {
+	// Index is the position of the event in the events given to LogEvents, or in the batch of the Writer
+	Index int
+	// Event is the JSON of the event
+	Event json.RawMessage
+	// Response is the answer of Splunk, with the InvalidEventNumber of the event in its request
+	Response *EventCollectorResponse
+}
+
+func (e *InvalidEventError) Error() string {
+	return fmt.Sprintf("splunk rejected event %d: %s", e.Index, e.Response.Error())
+}
+
+func (e *InvalidEventError) Unwrap() error {
+	return e.Response
+}
+
+// encodeEvents serializes each event for the event endpoint
+func encodeEvents(events []*Event) ([][]byte, error) {
+	encoded := make([][]byte, len(events))
+	for i, e := range events {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+	}
+	return encoded, nil
+}
+
+// joinEvents builds the request body of serialized events
+func joinEvents(encoded [][]byte) []byte {
+	buf := new(bytes.Buffer)
+	for _, b := range encoded {
+		buf.Write(b)
+		// Each json object should be separated by a blank line
+		buf.Write(eventSeparator)
+	}
+	return buf.Bytes()
+}
+
+// splitEvents returns the serialized events of a request body
+func splitEvents(body []byte) [][]byte {
+	var encoded [][]byte
+	for _, b := range bytes.Split(body, eventSeparator) {
+		if len(b) > 0 {
+			encoded = append(encoded, b)
+		}
+	}
+	return encoded
+}
+
+// maxBatchBytes returns the max size of a request body
+func (c *Client) maxBatchBytes() int {
+	if c.MaxBatchBytes <= 0 {
+		return defaultMaxBatchBytes
+	}
+	return c.MaxBatchBytes
+}
+
+// chunkSize returns how many of the serialized events fit in the next request, at least one
+func (c *Client) chunkSize(encoded [][]byte) int {
+	maxBytes := c.maxBatchBytes()
+	size := 0
+	for i, b := range encoded {
+		size += len(b) + len(eventSeparator)
+		if i > 0 && (size > maxBytes || c.MaxBatchEvents > 0 && i >= c.MaxBatchEvents) {
+			return i
+		}
+	}
+	return len(encoded)
+}
+
+// sendEncoded sends the serialized events in requests within MaxBatchBytes and MaxBatchEvents.
+// When Splunk rejects an event with InvalidDataFormat, it indexed the events before it, so the following
+// events are sent again without it. done is the number of leading events delivered or rejected,
+// offset being added to the Index of the rejected ones.
+func (c *Client) sendEncoded(encoded [][]byte, offset int) (done int, rejected []*InvalidEventError, err error) {
+	for done < len(encoded) {
+		n := c.chunkSize(encoded[done:])
+		chunk := encoded[done : done+n]
+		err = c.sendEvents(joinEvents(chunk))
+		var hecResp *EventCollectorResponse
+		if err != nil && errors.As(err, &hecResp) && hecResp.Code == InvalidDataFormat && hecResp.InvalidEventNumber != nil &&
+			*hecResp.InvalidEventNumber >= 0 && *hecResp.InvalidEventNumber < n {
+			invalid := *hecResp.InvalidEventNumber
+			rejected = append(rejected, &InvalidEventError{Index: offset + done + invalid, Event: chunk[invalid], Response: hecResp})
+			done += invalid + 1
+			continue
+		}
+		if err != nil {
+			return done, rejected, err
+		}
+		done += n
+	}
+	return done, rejected, nil
+}
+
+// rawChunks splits the data of the raw endpoint at line breaks, in requests within MaxBatchBytes
+func (c *Client) rawChunks(data []byte) [][]byte {
+	maxBytes := c.maxBatchBytes()
+	var chunks [][]byte
+	for len(data) > maxBytes {
+		end := bytes.LastIndexByte(data[:maxBytes], '\n') + 1
+		if end == 0 {
+			// a line longer than the limit is sent on its own
+			if end = bytes.IndexByte(data, '\n') + 1; end == 0 {
+				end = len(data)
+			}
+		}
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+	if len(data) > 0 {
+		chunks = append(chunks, data)
+	}
+	return chunks
+}
@@ -0,0 +1,141 @@
+package splunk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// strictServer indexes the events of each request up to the first one holding "bad",
+// which it rejects with its InvalidEventNumber like HEC does
+type strictServer struct {
+	mu       sync.Mutex
+	requests int
+	events   []string
+}
+
+func (s *strictServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	for i, encoded := range splitEvents(b) {
+		event := string(encoded)
+		if strings.Contains(event, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"text":"Invalid data format","code":6,"invalid-event-number":%d}`, i)
+			return
+		}
+		s.events = append(s.events, event[strings.Index(event, `"event":`)+len(`"event":`):len(event)-1])
+	}
+}
+
+func (s *strictServer) received() (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, strings.Join(s.events, ",")
+}
+
+func newEvents(client *Client, names ...string) []*Event {
+	events := make([]*Event, len(names))
+	for i, name := range names {
+		events[i] = client.NewEvent(name, "", "", "")
+	}
+	return events
+}
+
+func TestClient_LogEventsLimits(t *testing.T) {
+	s := &strictServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL, "token", "", "", "")
+	client.MaxBatchEvents = 2
+	if err := client.LogEvents(newEvents(client, "a", "b", "c", "d", "e")); err != nil {
+		t.Fatalf("LogEvents failed: %v", err)
+	}
+	if requests, events := s.received(); requests != 3 || events != `"a","b","c","d","e"` {
+		t.Errorf("Expected 3 requests with the events in order, got %d %s", requests, events)
+	}
+
+	s = &strictServer{}
+	bySize := httptest.NewServer(s)
+	defer bySize.Close()
+	client = NewClient(bySize.Client(), bySize.URL, "token", "", "", "")
+	client.MaxBatchBytes = 1
+	if err := client.LogEvents(newEvents(client, "a", "b", "c")); err != nil {
+		t.Fatalf("LogEvents failed: %v", err)
+	}
+	if requests, _ := s.received(); requests != 3 {
+		t.Errorf("Expected one request per event over the size limit, got %d", requests)
+	}
+}
+
+func TestClient_LogEventsInvalidEvent(t *testing.T) {
+	s := &strictServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL, "token", "", "", "")
+	err := client.LogEvents(newEvents(client, "a", "bad", "c", "also bad", "e"))
+	if _, events := s.received(); events != `"a","c","e"` {
+		t.Errorf("Expected the valid events to be delivered, got %s", events)
+	}
+	var invalid *InvalidEventError
+	if !errors.As(err, &invalid) || invalid.Index != 1 || string(invalid.Event) == "" || invalid.Response.Code != InvalidDataFormat {
+		t.Fatalf("Expected the first rejected event, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "splunk rejected event 3") {
+		t.Errorf("Expected both rejected events, got %v", err)
+	}
+}
+
+func TestWriter_InvalidEvent(t *testing.T) {
+	s := &strictServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	writer := Writer{
+		Client:         NewClient(server.Client(), server.URL, "token", "", "", ""),
+		FlushThreshold: 1000,
+		FlushInterval:  5 * time.Minute,
+		MaxRetries:     2,
+	}
+	defer writer.Close(context.Background())
+	for _, event := range []string{`"a"`, `"bad"`, `"c"`} {
+		_, _ = writer.Write([]byte(event))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Expected the batch without the rejected event to be delivered, got %v", err)
+	}
+	if requests, events := s.received(); requests != 2 || events != `"a","c"` {
+		t.Errorf("Expected the remainder to be sent once, got %d %s", requests, events)
+	}
+	var invalid *InvalidEventError
+	if err := <-writer.Errors(); !errors.As(err, &invalid) || invalid.Index != 1 || !strings.Contains(string(invalid.Event), `"event":"bad"`) {
+		t.Errorf("Expected the rejected event on Errors, got %v", err)
+	}
+	if writer.Dropped() != 1 {
+		t.Errorf("Expected the rejected event to be dropped, got %d", writer.Dropped())
+	}
+}
+
+func TestClient_RawChunks(t *testing.T) {
+	client := &Client{MaxBatchBytes: 10}
+	chunks := client.rawChunks([]byte("short\nshort\na line over the limit\nend\n"))
+	expected := []string{"short\n", "short\n", "a line over the limit\n", "end\n"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, chunks)
+	}
+	for i, chunk := range chunks {
+		if string(chunk) != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], chunk)
+		}
+	}
+}
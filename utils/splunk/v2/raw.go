@@ -0,0 +1,76 @@
+package splunk
+
+import (
+	"bytes"
+	"net/url"
+)
+
+const (
+	rawPath        = collectorPath + "/raw"
+	rawContentType = "text/plain"
+)
+
+// Client.LogRaw is used to POST unstructured data to the raw endpoint of the Splunk server, without wrapping it in an Event.
+//
+// Splunk breaks the data into events with the line breaking of the sourcetype, one event per line by default.
+// The host, source, sourcetype and index of the Client are passed as query parameters.
+// The raw endpoint requires a channel, see Client.Channel.
+// Data larger than MaxBatchBytes is sent in several requests, split at line breaks.
+func (c *Client) LogRaw(data []byte) error {
+	for _, chunk := range c.rawChunks(data) {
+		if err := c.sendRaw(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendRaw POSTs data to the raw endpoint with a single request
+func (c *Client) sendRaw(data []byte) error {
+	rawURL, err := c.rawURL()
+	if err != nil {
+		return err
+	}
+	if c.Ack != nil {
+		return c.sendWithAck(rawURL, rawContentType, data)
+	}
+	_, err = c.doRequest(rawURL, rawContentType, bytes.NewBuffer(data))
+	return err
+}
+
+// Client.LogRawLines is used to POST several lines to the raw endpoint of the Splunk server, see LogRaw.
+// The lines are separated by a line break, a trailing one is ignored.
+func (c *Client) LogRawLines(lines [][]byte) error {
+	return c.LogRaw(rawLinesBody(lines))
+}
+
+// rawLinesBody joins the lines for the raw endpoint
+func rawLinesBody(lines [][]byte) []byte {
+	buf := new(bytes.Buffer)
+	for _, line := range lines {
+		buf.Write(bytes.TrimSuffix(line, []byte("\n")))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// rawURL returns the URL of the raw endpoint, with the metadata of the Client in its query
+func (c *Client) rawURL() (string, error) {
+	u, err := url.Parse(c.collectorURL(rawPath))
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, value := range map[string]string{
+		"host":       c.Hostname,
+		"source":     c.Source,
+		"sourcetype": c.SourceType,
+		"index":      c.Index,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
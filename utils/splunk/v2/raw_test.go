@@ -0,0 +1,100 @@
+package splunk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type rawRequest struct {
+	path    string
+	query   map[string]string
+	channel string
+	body    string
+}
+
+func newRawServer(requests chan<- rawRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		query := make(map[string]string)
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+		requests <- rawRequest{path: r.URL.Path, query: query, channel: r.Header.Get("X-Splunk-Request-Channel"), body: string(b)}
+	}))
+}
+
+func TestClient_LogRawLines(t *testing.T) {
+	requests := make(chan rawRequest, 1)
+	server := newRawServer(requests)
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL+"/services/collector", "token", "nginx", "access_combined", "web")
+	client.Hostname = "web-1"
+
+	if err := client.LogRawLines([][]byte{[]byte("GET / 200\n"), []byte("GET /rooms 404")}); err != nil {
+		t.Fatalf("LogRawLines failed: %v", err)
+	}
+	request := <-requests
+	if request.path != "/services/collector/raw" {
+		t.Errorf("Expected the raw endpoint, got %s", request.path)
+	}
+	if request.body != "GET / 200\nGET /rooms 404\n" {
+		t.Errorf("Expected one line per entry, got %q", request.body)
+	}
+	expected := map[string]string{"host": "web-1", "source": "nginx", "sourcetype": "access_combined", "index": "web"}
+	for key, value := range expected {
+		if request.query[key] != value {
+			t.Errorf("Expected %s=%s in the query, got %v", key, value, request.query)
+		}
+	}
+	if request.channel == "" || request.channel != client.Channel {
+		t.Errorf("Expected the generated channel, got %q", request.channel)
+	}
+}
+
+func TestClient_LogRawError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"text":"Data channel is missing","code":10}`))
+	}))
+	defer server.Close()
+	client := NewClient(server.Client(), server.URL, "token", "", "", "")
+	var hecResp *EventCollectorResponse
+	if err := client.LogRaw([]byte("line")); !errors.As(err, &hecResp) || hecResp.Code != DataChannelMissing {
+		t.Errorf("Expected DataChannelMissing, got %v", err)
+	}
+}
+
+func TestWriter_Raw(t *testing.T) {
+	requests := make(chan rawRequest, 2)
+	server := newRawServer(requests)
+	defer server.Close()
+	writer := Writer{
+		Client:        NewClient(server.Client(), server.URL+"/services/collector/event", "token", "app", "", ""),
+		Raw:           true,
+		FlushInterval: 5 * time.Minute,
+	}
+	_, _ = writer.Write([]byte("first line\n"))
+	_, _ = writer.Write([]byte("second line\n"))
+	_ = writer.WriteEvent(&Event{Event: "structured"})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	close(requests)
+	paths := make(map[string]string)
+	for request := range requests {
+		paths[request.path] = request.body
+	}
+	if paths["/services/collector/raw"] != "first line\nsecond line\n" {
+		t.Errorf("Expected the written lines on the raw endpoint, got %q", paths["/services/collector/raw"])
+	}
+	if _, hasEvent := paths["/services/collector/event"]; !hasEvent {
+		t.Errorf("Expected the event on the event endpoint, got %v", paths)
+	}
+}
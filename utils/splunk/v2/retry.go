@@ -0,0 +1,110 @@
+package splunk
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultMaxRetryBackoff = 30 * time.Second
+)
+
+// PermanentError is returned for the failures that sending the same request again can't fix,
+// such as InvalidToken, TokenDisabled, IncorrectIndex or InvalidDataFormat. Writer never retries them.
+// The *EventCollectorResponse is no longer returned as is, use errors.As rather than a type assertion to get it.
+type PermanentError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Err is the *EventCollectorResponse of Splunk, or the response body when it could not be decoded
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return "permanent splunk failure: " + e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// TemporaryError is returned for the failures worth retrying later, i.e. ServerBusy, a server error
+// or 429 Too Many Requests. Writer retries them with a backoff, or after RetryAfter when Splunk asked for it,
+// waiting at most MaxRetryBackoff. Like PermanentError, it wraps the *EventCollectorResponse.
+type TemporaryError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// RetryAfter is the delay of the Retry-After header, 0 without one
+	RetryAfter time.Duration
+	// Err is the *EventCollectorResponse of Splunk, or the response body when it could not be decoded
+	Err error
+}
+
+func (e *TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+// statusError types the error of a failed response after its status
+func statusError(res *http.Response, err error) error {
+	var hecResp *EventCollectorResponse
+	isHECResponse := errors.As(err, &hecResp)
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError ||
+		isHECResponse && (hecResp.Code == ServerBusy || hecResp.Code == InternalServerError) {
+		return &TemporaryError{StatusCode: res.StatusCode, RetryAfter: retryAfter(res.Header.Get("Retry-After")), Err: err}
+	}
+	return &PermanentError{StatusCode: res.StatusCode, Err: err}
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// isRetryable reports whether sending again may succeed: temporary and network errors are, permanent ones
+// and rejected events are not
+func isRetryable(err error) bool {
+	var permanent *PermanentError
+	var invalidEvent *InvalidEventError
+	return !errors.As(err, &permanent) && !errors.As(err, &invalidEvent) && !errors.Is(err, ErrAckIdMissing)
+}
+
+// retryDelay returns how long to wait before the retry following the failed attempt, counted from 0, at most MaxRetryBackoff.
+// The exponential backoff is jittered so the writers of several processes don't retry in lockstep.
+func (w *Writer) retryDelay(err error, attempt int) time.Duration {
+	backoff, maxBackoff := w.RetryBackoff, w.MaxRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxRetryBackoff
+	}
+	var temporary *TemporaryError
+	if errors.As(err, &temporary) && temporary.RetryAfter > 0 {
+		// clamped, so that a large Retry-After can't hold a batch for hours
+		return min(temporary.RetryAfter, maxBackoff)
+	}
+	delay := maxBackoff
+	if attempt < 32 && backoff<<attempt > 0 && backoff<<attempt < maxBackoff {
+		delay = backoff << attempt
+	}
+	// between half and all of the delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,132 @@
+package splunk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriter_RetryTemporary(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"text":"Server is busy","code":9}`)
+		}
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:       NewClient(server.Client(), server.URL, "", "", "", ""),
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	}
+	if err := writer.send([]*message{{data: []byte(`"some data"`)}}, 0, writer.MaxRetries); err != nil {
+		t.Fatalf("Expected the retries to deliver the batch, got %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestWriter_RetryPermanent(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"text":"Invalid token","code":4}`)
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:       NewClient(server.Client(), server.URL, "", "", "", ""),
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	}
+	writer.init()
+	err := writer.send([]*message{{data: []byte(`"some data"`)}}, 0, writer.MaxRetries)
+	var permanent *PermanentError
+	var response *EventCollectorResponse
+	if !errors.As(err, &permanent) || permanent.StatusCode != http.StatusForbidden || !errors.As(err, &response) || response.Code != InvalidToken {
+		t.Fatalf("Expected a permanent InvalidToken error, got %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected no retry, got %d requests", requests.Load())
+	}
+	if reported := <-writer.Errors(); reported != err {
+		t.Errorf("Expected the error on Errors, got %v", reported)
+	}
+}
+
+func TestWriter_RetryDelay(t *testing.T) {
+	writer := Writer{RetryBackoff: 100 * time.Millisecond, MaxRetryBackoff: time.Second}
+	for attempt, maximum := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := writer.retryDelay(errors.New("connection refused"), attempt)
+		if delay < maximum/2 || delay > maximum {
+			t.Errorf("Expected a delay between %v and %v for attempt %d, got %v", maximum/2, maximum, attempt, delay)
+		}
+	}
+	err := &TemporaryError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 700 * time.Millisecond, Err: errors.New("busy")}
+	if delay := writer.retryDelay(err, 0); delay != 700*time.Millisecond {
+		t.Errorf("Expected the Retry-After delay, got %v", delay)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if delay := retryAfter("7"); delay != 7*time.Second {
+		t.Errorf("Expected 7s, got %v", delay)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := retryAfter(date); delay < 58*time.Second || delay > time.Minute {
+		t.Errorf("Expected about a minute for %s, got %v", date, delay)
+	}
+	for _, header := range []string{"", "soon", "-1"} {
+		if delay := retryAfter(header); delay != 0 {
+			t.Errorf("Expected no delay for %q, got %v", header, delay)
+		}
+	}
+}
+
+func TestWriter_RetryAfterClamped(t *testing.T) {
+	writer := Writer{MaxRetryBackoff: time.Second}
+	err := &TemporaryError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour, Err: errors.New("slow down")}
+	if delay := writer.retryDelay(err, 0); delay != time.Second {
+		t.Errorf("Expected the Retry-After delay clamped to MaxRetryBackoff, got %v", delay)
+	}
+}
+
+func TestWriter_CloseInterruptsRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"text":"Server is busy","code":9}`)
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:          NewClient(server.Client(), server.URL, "", "", "", ""),
+		MaxRetries:      3,
+		MaxRetryBackoff: time.Hour,
+	}
+	_, _ = writer.Write([]byte(`"some data"`))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := writer.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Close to give up with its context, got %v", err)
+	}
+	select {
+	case <-writer.done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the retry delay to be interrupted by Close")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected no retry after Close gave up, got %d requests", requests.Load())
+	}
+	if writer.Dropped() != 1 {
+		t.Errorf("Expected the batch to be dropped, got %d", writer.Dropped())
+	}
+}
@@ -0,0 +1,216 @@
+package splunk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSpillMaxBytes       = 512 << 20
+	defaultSpillReplayInterval = 5 * time.Second
+	eventsSegment              = ".events"
+	rawSegment                 = ".raw"
+	tmpSegment                 = ".tmp"
+)
+
+// ErrSpillFull is returned when a batch does not fit in the disk budget of the spill directory, the batch is dropped
+var ErrSpillFull = errors.New("splunk spill directory is over its disk budget")
+
+// SpillOptions configures the disk-backed queue of a Writer.
+// The batches that could not be delivered, retries included, are written to segment files in Dir instead of being dropped.
+// They are sent again in the order they were written once Splunk is reachable, including after a restart.
+type SpillOptions struct {
+	// Dir holds the segment files, it is created if needed. A directory must not be shared by several writers.
+	Dir string
+	// Max number of bytes of the segment files, 512MB by default. Batches are dropped once it is reached.
+	MaxBytes int64
+	// How often the segment files are sent again, 5 seconds by default
+	ReplayInterval time.Duration
+}
+
+// spill is the queue of segment files of a Writer, each holding the serialized events or lines of one batch
+type spill struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+	size     int64
+	next     uint64
+}
+
+// openSpill opens the spill directory, picking up the segments left by a previous process
+func openSpill(options *SpillOptions) (*spill, error) {
+	if options.Dir == "" {
+		return nil, errors.New("splunk spill directory is not set")
+	}
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &spill{dir: options.Dir, maxBytes: options.MaxBytes}
+	if s.maxBytes <= 0 {
+		s.maxBytes = defaultSpillMaxBytes
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, tmpSegment) {
+			// a segment the previous process did not finish writing
+			_ = os.Remove(filepath.Join(s.dir, name))
+			continue
+		}
+		seq, isSegment := segmentSequence(name)
+		if !isSegment {
+			continue
+		}
+		if info, infoError := entry.Info(); infoError == nil {
+			s.size += info.Size()
+		}
+		if seq >= s.next {
+			s.next = seq + 1
+		}
+	}
+	return s, nil
+}
+
+// segmentSequence returns the sequence number of a segment file name
+func segmentSequence(name string) (uint64, bool) {
+	ext := filepath.Ext(name)
+	if ext != eventsSegment && ext != rawSegment {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+	return seq, err == nil
+}
+
+// reserve returns the sequence number of the segments of a new batch.
+// Batches are spilled whenever their send gives up, their reserved number keeps them in the order they were built.
+func (s *spill) reserve() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seq := s.next
+	s.next++
+	return seq
+}
+
+// add writes the body to the segment of the batch seq, kind being eventsSegment or rawSegment
+func (s *spill) add(seq uint64, kind string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+int64(len(body)) > s.maxBytes {
+		return ErrSpillFull
+	}
+	// zero padded so the names sort in the order of the segments
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, kind))
+	// written aside then renamed, so a crash never leaves a truncated segment
+	if err := os.WriteFile(path+tmpSegment, body, 0o644); err != nil {
+		_ = os.Remove(path + tmpSegment)
+		return err
+	}
+	if err := os.Rename(path+tmpSegment, path); err != nil {
+		return err
+	}
+	s.size += int64(len(body))
+	return nil
+}
+
+// empty reports whether no segment is waiting
+func (s *spill) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size == 0
+}
+
+// replay sends the segments in order, stopping at the first one that fails with a temporary error.
+// The segments rejected with a permanent error are removed, their errors and the events Splunk rejected
+// as malformed are passed to report. A segment partly sent is rewritten with what is left.
+func (s *spill) replay(c *Client, report func(error)) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, isSegment := segmentSequence(entry.Name()); isSegment {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		body, readError := os.ReadFile(path)
+		if readError != nil {
+			return readError
+		}
+		var left []byte
+		if filepath.Ext(name) == rawSegment {
+			chunks := c.rawChunks(body)
+			for len(chunks) > 0 {
+				if err = c.sendRaw(chunks[0]); err != nil {
+					break
+				}
+				chunks = chunks[1:]
+			}
+			left = bytes.Join(chunks, nil)
+		} else {
+			encoded := splitEvents(body)
+			var done int
+			var rejected []*InvalidEventError
+			done, rejected, err = c.sendEncoded(encoded, 0)
+			for _, r := range rejected {
+				report(r)
+			}
+			left = joinEvents(encoded[done:])
+		}
+		if err != nil && isRetryable(err) {
+			if len(left) < len(body) {
+				if rewriteError := s.rewrite(path, int64(len(body)), left); rewriteError != nil {
+					report(rewriteError)
+				}
+			}
+			return err
+		}
+		if err != nil {
+			report(err)
+		}
+		if err = s.remove(path, int64(len(body))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite replaces the body of a segment with the part left to send
+func (s *spill) rewrite(path string, size int64, left []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.WriteFile(path+tmpSegment, left, 0o644); err != nil {
+		_ = os.Remove(path + tmpSegment)
+		return err
+	}
+	if err := os.Rename(path+tmpSegment, path); err != nil {
+		return err
+	}
+	s.size += int64(len(left)) - size
+	return nil
+}
+
+// remove deletes a segment that was delivered or rejected
+func (s *spill) remove(path string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	s.size -= size
+	return nil
+}
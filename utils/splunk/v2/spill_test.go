@@ -0,0 +1,183 @@
+package splunk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// outageServer answers 503 until it is up, and records the events it received
+type outageServer struct {
+	up     atomic.Bool
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *outageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.up.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"text":"Server is busy","code":9}`)
+		return
+	}
+	b, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range strings.Split(string(b), "\r\n\r\n") {
+		if start := strings.Index(line, `"event":`); start >= 0 {
+			s.events = append(s.events, strings.TrimSuffix(line[start+len(`"event":`):], "}"))
+		}
+	}
+}
+
+func (s *outageServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.events...)
+}
+
+func newSpillWriter(server *httptest.Server, dir string) *Writer {
+	return &Writer{
+		Client:         NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushThreshold: 1000,
+		FlushInterval:  5 * time.Minute,
+		Spill:          &SpillOptions{Dir: dir, ReplayInterval: 5 * time.Millisecond},
+	}
+}
+
+func TestWriter_Spill(t *testing.T) {
+	s := &outageServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	dir := t.TempDir()
+	writer := newSpillWriter(server, dir)
+	defer writer.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, event := range []string{`"first"`, `"second"`, `"third"`} {
+		_, _ = writer.Write([]byte(event))
+		if err := writer.Flush(ctx); err != nil {
+			t.Fatalf("Expected the batch to be spilled, got %v", err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(entries))
+	}
+	s.up.Store(true)
+	deadline := time.Now().Add(time.Second)
+	for entries, _ := os.ReadDir(dir); len(entries) > 0 && time.Now().Before(deadline); entries, _ = os.ReadDir(dir) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if received := strings.Join(s.received(), ","); received != `"first","second","third"` {
+		t.Errorf("Expected the spilled batches in order, got %s", received)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the replayed segments to be removed, got %d", len(entries))
+	}
+	if writer.Dropped() != 0 {
+		t.Errorf("Expected nothing dropped, got %d", writer.Dropped())
+	}
+}
+
+func TestWriter_SpillRestart(t *testing.T) {
+	s := &outageServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	writer := newSpillWriter(server, dir)
+	_, _ = writer.Write([]byte(`"before restart"`))
+	if err := writer.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s.up.Store(true)
+	restarted := newSpillWriter(server, dir)
+	defer restarted.Close(context.Background())
+	// written while the segment of the previous writer waits, it is queued behind it
+	_, _ = restarted.Write([]byte(`"after restart"`))
+	if err := restarted.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(s.received()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if received := strings.Join(s.received(), ","); received != `"before restart","after restart"` {
+		t.Errorf("Expected both batches in order, got %s", received)
+	}
+}
+
+func TestWriter_SpillFull(t *testing.T) {
+	server := httptest.NewServer(&outageServer{})
+	defer server.Close()
+	writer := newSpillWriter(server, t.TempDir())
+	writer.Spill.MaxBytes = 10
+	defer writer.Close(context.Background())
+	_, _ = writer.Write([]byte(`"too big for the disk budget"`))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var temporary *TemporaryError
+	if err := writer.Flush(ctx); !errors.As(err, &temporary) {
+		t.Errorf("Expected the delivery error, got %v", err)
+	}
+	if writer.Dropped() != 1 {
+		t.Errorf("Expected the message to be dropped, got %d", writer.Dropped())
+	}
+	reported := []error{<-writer.Errors(), <-writer.Errors()}
+	if !errors.Is(reported[1], ErrSpillFull) {
+		t.Errorf("Expected ErrSpillFull on Errors, got %v", reported)
+	}
+}
+
+func TestWriter_SpillOverflowOrder(t *testing.T) {
+	s := &outageServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	dir := t.TempDir()
+	writer := newSpillWriter(server, dir)
+	writer.FlushThreshold = 1
+	writer.MaxConcurrentSends = 1
+	writer.MaxQueuedBatches = 1
+	defer writer.Close(context.Background())
+
+	// the queue and the buffer overflow while the batches fail, every batch is spilled whole
+	expected := make([]string, 3*bufferSize)
+	for i := range expected {
+		expected[i] = strconv.Itoa(i)
+		if _, err := writer.Write([]byte(expected[i])); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Expected every batch to be spilled, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > len(expected)/2+1 {
+		t.Errorf("Expected one segment per batch, got %d for %d messages", len(entries), len(expected))
+	}
+	s.up.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for entries, _ := os.ReadDir(dir); len(entries) > 0 && time.Now().Before(deadline); entries, _ = os.ReadDir(dir) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if received := strings.Join(s.received(), ","); received != strings.Join(expected, ",") {
+		t.Errorf("Expected the messages replayed in order, got %s", received)
+	}
+	if writer.Dropped() != 0 {
+		t.Errorf("Expected nothing dropped, got %d", writer.Dropped())
+	}
+}
@@ -9,9 +9,15 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
+const (
+	collectorPath   = "/services/collector"
+	jsonContentType = "application/json"
+)
+
 // Event represents the log event object that is sent to Splunk when Client.Log is called.
 type Event struct {
 	Time       EventTime   `json:"time"`                 // when the event happened
@@ -20,6 +26,8 @@ type Event struct {
 	SourceType string      `json:"sourcetype,omitempty"` // optional name of a Splunk parsing configuration; this is usually inferred by Splunk
 	Index      string      `json:"index,omitempty"`      // optional name of the Splunk index to store the event in; not required if the token has a default index set in Splunk
 	Event      interface{} `json:"event"`                // throw any useful key/val pairs here
+	// optional indexed fields, searchable without extracting them from the event; https://docs.splunk.com/Documentation/Splunk/latest/Data/IFXandHEC
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
 
 // EventTime marshals timestamps using the Splunk HTTP Event Collector's default format.
@@ -45,6 +53,22 @@ type Client struct {
 	Source     string //Default source
 	SourceType string //Default source type
 	Index      string //Default index
+	// Channel is the GUID sent in the X-Splunk-Request-Channel header, generated by the first request when empty.
+	// Indexer acknowledgement and the raw endpoint require one. It must not be changed once the Client sent a request.
+	Channel string
+	// Ack enables indexer acknowledgement when set, see AckOptions
+	Ack *AckOptions
+	// Max size of a request body, 1MB by default, and max number of events per request, unlimited by default.
+	// Larger batches are sent in several requests.
+	MaxBatchBytes  int
+	MaxBatchEvents int
+	// Gzip compresses the request bodies with GzipLevel, gzip.DefaultCompression when unset
+	Gzip      bool
+	GzipLevel int
+	// acks polls the acknowledgement status of the requests waiting for it
+	acks ackPoller
+	// channelOnce sets Channel before any request reads it
+	channelOnce sync.Once
 }
 
 // NewClient creates a new client to Splunk.
@@ -91,7 +115,7 @@ func (c *Client) NewEvent(event interface{}, source string, sourcetype string, i
 // the case, use this function to create the Event object and the the LogEvent function.
 func (c *Client) NewEventWithTime(t time.Time, event interface{}, source string, sourcetype string, index string) *Event {
 	e := &Event{
-		Time:       EventTime{time.Now()},
+		Time:       EventTime{t},
 		Host:       c.Hostname,
 		Source:     source,
 		SourceType: sourcetype,
@@ -123,28 +147,33 @@ func (c *Client) LogWithTime(t time.Time, event interface{}) error {
 
 // Client.LogEvent is used to POST a single event to the Splunk server.
 func (c *Client) LogEvent(e *Event) error {
-	// Convert requestBody struct to byte slice to prep for http.NewRequest
-	b, err := json.Marshal(e)
+	return c.LogEvents([]*Event{e})
+}
+
+// Client.LogEvents is used to POST multiple events to the Splunk server, in requests within MaxBatchBytes and MaxBatchEvents.
+// With indexer acknowledgement enabled, it returns once Splunk has indexed the events.
+// The events Splunk rejects as malformed are returned as *InvalidEventError, joined with errors.Join, the others being delivered.
+func (c *Client) LogEvents(events []*Event) error {
+	encoded, err := encodeEvents(events)
 	if err != nil {
 		return err
 	}
-	return c.doRequest(bytes.NewBuffer(b))
+	_, rejected, err := c.sendEncoded(encoded, 0)
+	errs := make([]error, 0, len(rejected)+1)
+	for _, r := range rejected {
+		errs = append(errs, r)
+	}
+	return errors.Join(append(errs, err)...)
 }
 
-// Client.LogEvents is used to POST multiple events with a single request to the Splunk server.
-func (c *Client) LogEvents(events []*Event) error {
-	buf := new(bytes.Buffer)
-	for _, e := range events {
-		b, err := json.Marshal(e)
-		if err != nil {
-			return err
-		}
-		buf.Write(b)
-		// Each json object should be separated by a blank line
-		buf.WriteString("\r\n\r\n")
+// sendEvents POSTs serialized events to the event endpoint with a single request
+func (c *Client) sendEvents(body []byte) error {
+	if c.Ack != nil {
+		return c.sendWithAck(c.URL, jsonContentType, body)
 	}
 	// Convert requestBody struct to byte slice to prep for http.NewRequest
-	return c.doRequest(buf)
+	_, err := c.doRequest(c.URL, jsonContentType, bytes.NewBuffer(body))
+	return err
 }
 
 // Writer is a convience method for creating an io.Writer from a Writer with default values
@@ -155,44 +184,71 @@ func (c *Client) Writer() io.Writer {
 }
 
 // Client.doRequest is used internally to POST the bytes of events to the Splunk server.
-func (c *Client) doRequest(b *bytes.Buffer) error {
+func (c *Client) doRequest(url string, contentType string, b *bytes.Buffer) (*EventCollectorResponse, error) {
+	respBody, err := c.post(url, contentType, b)
+	if err != nil {
+		return nil, err
+	}
+	// the body of a successful request only matters for its ackId
+	hecResp := &EventCollectorResponse{}
+	_ = json.Unmarshal(respBody, hecResp)
+	return hecResp, nil
+}
+
+// Client.post sends a request to an Event Collector endpoint and returns the body of a successful response.
+func (c *Client) post(url string, contentType string, b *bytes.Buffer) ([]byte, error) {
+	var body io.Reader = b
+	var compressed *pooledBody
+	if c.Gzip {
+		var compressError error
+		if compressed, compressError = c.compress(b.Bytes()); compressError != nil {
+			return nil, compressError
+		}
+		// closed by the transport, even when the request fails
+		body = compressed
+	}
 	// make new request
-	url := c.URL
-	req, newRequestError := http.NewRequest("POST", url, b)
+	req, newRequestError := http.NewRequest("POST", url, body)
 	if newRequestError != nil {
-		return newRequestError
+		if compressed != nil {
+			compressed.Close()
+		}
+		return nil, newRequestError
 	}
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", contentType)
 	req.Header.Add("Authorization", "Splunk "+c.Token)
+	if compressed != nil {
+		req.ContentLength = int64(compressed.Len())
+		req.Header.Add("Content-Encoding", "gzip")
+	}
+	req.Header.Add("X-Splunk-Request-Channel", c.channel())
 
 	// receive response
 	res, httpClientDoRequestError := c.HTTPClient.Do(req)
 	if httpClientDoRequestError != nil {
-		return httpClientDoRequestError
+		return nil, httpClientDoRequestError
 	}
 
 	// need to make sure we close the body to avoid hanging the connection
 	defer res.Body.Close()
 
+	respBody, ioReadResponseError := io.ReadAll(res.Body)
+	if ioReadResponseError != nil {
+		return nil, ioReadResponseError
+	}
+
 	// If statusCode is not OK, return the error
 	switch res.StatusCode {
 	case 200:
-		// need to read the reply otherwise the connection hangs
-		io.Copy(io.Discard, res.Body)
-		return nil
+		return respBody, nil
 	default:
-		respBody, ioReadResponseError := io.ReadAll(res.Body)
-		if ioReadResponseError != nil {
-			return ioReadResponseError
-		}
-
 		// try deserializing response body to a typed HEC response
 		hecResp := &EventCollectorResponse{}
-		if err := json.Unmarshal(respBody, hecResp); err == nil {
-			return hecResp
+		if unmarshalError := json.Unmarshal(respBody, hecResp); unmarshalError == nil {
+			return nil, statusError(res, hecResp)
 		}
 
 		// otherwise, return the response body as an error string
-		return errors.New(string(respBody))
+		return nil, statusError(res, errors.New(string(respBody)))
 	}
 }
@@ -1,35 +1,98 @@
 package splunk
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	bufferSize       = 100
-	defaultInterval  = 2 * time.Second
-	defaultThreshold = 10
-	defaultRetries   = 2
+	bufferSize            = 100
+	defaultInterval       = 2 * time.Second
+	defaultThreshold      = 10
+	defaultConcurrentSend = 4
+	defaultQueuedBatches  = 100
+	// maxOverflow is the max number of messages waiting for room in a full buffer
+	maxOverflow = 100 * bufferSize
 )
 
+// ErrWriterClosed is returned by Write once the Writer is closed
+var ErrWriterClosed = errors.New("splunk writer is closed")
+
+// ErrQueueFull is reported on Errors for the batches dropped because MaxQueuedBatches were waiting for a send
+var ErrQueueFull = errors.New("splunk writer queue is full")
+
+// ErrBufferFull is returned by Write when the buffer and its overflow are full, the message is dropped
+var ErrBufferFull = errors.New("splunk writer buffer is full")
+
 // Writer is a threadsafe, aysnchronous splunk writer.
 // It implements io.Writer for usage in logging libraries, or whatever you want to send to splunk :)
 // Writer.Client's configuration determines what source, sourcetype & index will be used for events
+// Call Close before the process exits so the buffered messages are delivered, Flush delivers them on demand.
 // Example for logrus:
-//    splunkWriter := &splunk.Writer {Client: client}
-//    logrus.SetOutput(io.MultiWriter(os.Stdout, splunkWriter))
+//
+//	splunkWriter := &splunk.Writer {Client: client}
+//	logrus.SetOutput(io.MultiWriter(os.Stdout, splunkWriter))
 type Writer struct {
 	Client *Client
 	// How often the write buffer should be flushed to splunk
 	FlushInterval time.Duration
 	// How many Write()'s before buffer should be flushed to splunk
 	FlushThreshold int
-	// Max number of retries we should do when we flush the buffer
+	// Max number of retries we should do when we flush the buffer.
+	// Only temporary failures are retried, see TemporaryError and PermanentError.
 	MaxRetries int
-	dataChan   chan *message
+	// Delay before the first retry, 500ms by default. It doubles on every retry up to MaxRetryBackoff, 30s by default,
+	// a Retry-After header of Splunk takes precedence.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// Send the written bytes as lines to the raw endpoint instead of wrapping each Write in an Event,
+	// e.g. to forward the output of nginx or another process. The events of WriteEvent are still sent as JSON.
+	Raw bool
+	// Max number of batches being sent at the same time, 4 by default.
+	// When they are all in flight, new batches are queued so Write never waits for Splunk.
+	MaxConcurrentSends int
+	// Max number of batches queued for a send, 100 by default. Beyond it the oldest queued batch is dropped.
+	MaxQueuedBatches int
+	// Spill keeps the batches that could not be delivered on disk until Splunk is reachable again, see SpillOptions.
+	// A spilled batch counts as delivered for Flush, its error is still reported on Errors.
+	Spill    *SpillOptions
+	dataChan chan *message
+	// overflow holds the messages written while dataChan was full, overflowed signals listen about them
+	overflowMu sync.Mutex
+	overflow   []*message
+	overflowed chan struct{}
 	errors     chan error
 	once       sync.Once
+	spill      *spill
+	// stopReplay stops the replay of the spilled batches, replayDone is closed once it returned
+	stopReplay     chan struct{}
+	replayDone     chan struct{}
+	stopReplayOnce sync.Once
+	dropped        atomic.Int64
+	// stopRetries is closed when the context of Close ends, so that no retry delay outlives it
+	stopRetries     chan struct{}
+	stopRetriesOnce sync.Once
+	// mu guards closed, Write holds it for reading so Close never closes dataChan under a pending Write
+	mu     sync.RWMutex
+	closed bool
+	// done is closed when listen has flushed the buffer and every send has returned
+	done chan struct{}
+	// sendSlots bounds the concurrent sends, inFlight holds the batches queued or being sent
+	sendSlots  chan struct{}
+	inFlightMu sync.Mutex
+	inFlight   map[*batch]struct{}
+	sending    sync.WaitGroup
+}
+
+// batch is a group of messages sent in one request, done is closed once it is delivered or given up
+type batch struct {
+	done chan struct{}
+	err  error
 }
 
 // Associates some bytes with the time they were written
@@ -38,37 +101,217 @@ type Writer struct {
 type message struct {
 	data      json.RawMessage
 	writtenAt time.Time
+	// event is a complete event given to WriteEvent, sent as is instead of data
+	event *Event
+	// flushed is set for the flush requests of Flush, it receives the batches to wait for
+	flushed chan []*batch
 }
 
 // Writer asynchronously writes to splunk in batches
 func (w *Writer) Write(b []byte) (int, error) {
-	// only initialize once. Keep all of our buffering in one thread
-	w.once.Do(func() {
-		// synchronously set up dataChan
-		w.dataChan = make(chan *message, bufferSize)
-		// Spin up single goroutine to listen to our writes
-		w.errors = make(chan error, bufferSize)
-		go w.listen()
-	})
+	w.init()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
 	// Make a local copy of the bytearray so it doesn't get overwritten by
 	// the next call to Write()
 	var b2 = make([]byte, len(b))
 	copy(b2, b)
 	// Send the data to the channel
-	w.dataChan <- &message{
+	if err := w.enqueue(&message{
 		data:      b2,
 		writtenAt: time.Now(),
+	}); err != nil {
+		return 0, err
 	}
 	// We don't know if we've hit any errors yet, so just say we're good
 	return len(b), nil
 }
 
+// WriteEvent asynchronously sends a complete event, with its own time, source, sourcetype, index and fields.
+// Unset source, sourcetype and index fall back to the configuration of Writer.Client.
+func (w *Writer) WriteEvent(e *Event) error {
+	w.init()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	return w.enqueue(&message{
+		writtenAt: e.Time.Time,
+		event:     e,
+	})
+}
+
+// enqueue hands the message to listen without waiting.
+// When the buffer is full the message goes to the overflow, listen takes it in order once the buffer is drained.
+// A message beyond maxOverflow is dropped, unless it is a flush request.
+func (w *Writer) enqueue(m *message) error {
+	w.overflowMu.Lock()
+	defer w.overflowMu.Unlock()
+	// once a message overflowed, the next ones follow it so that they keep their order
+	if len(w.overflow) == 0 {
+		select {
+		case w.dataChan <- m:
+			return nil
+		default:
+		}
+	}
+	if len(w.overflow) >= maxOverflow && m.flushed == nil {
+		w.dropped.Add(1)
+		return ErrBufferFull
+	}
+	w.overflow = append(w.overflow, m)
+	select {
+	case w.overflowed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// takeOverflow returns the overflowing messages, listen must have drained dataChan first
+func (w *Writer) takeOverflow() []*message {
+	w.overflowMu.Lock()
+	defer w.overflowMu.Unlock()
+	overflow := w.overflow
+	w.overflow = nil
+	return overflow
+}
+
+// Dropped returns the number of messages dropped, because the buffer was full,
+// or because their batch could neither be delivered nor spilled
+func (w *Writer) Dropped() int64 {
+	return w.dropped.Load()
+}
+
 // Errors returns a buffered channel of errors. Might be filled over time, might not
 // Useful if you want to record any errors hit when sending data to splunk
 func (w *Writer) Errors() <-chan error {
+	// the channel must exist before the first Write so callers can start listening right away
+	w.init()
 	return w.errors
 }
 
+// Flush sends the buffered messages and blocks until they are delivered, retries included, or the context ends.
+// It returns the error of the last failed batch, which is also reported on Errors.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.init()
+	flushed := make(chan []*batch, 1)
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return ErrWriterClosed
+	}
+	// the request goes through the buffer, after the messages written before it
+	_ = w.enqueue(&message{flushed: flushed})
+	w.mu.RUnlock()
+	var batches []*batch
+	select {
+	case batches = <-flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var err error
+	for _, b := range batches {
+		select {
+		case <-b.done:
+			if b.err != nil {
+				err = b.err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Close stops accepting writes and sends the buffered messages.
+// It blocks until they are delivered, retries included, or the context ends.
+// When the context ends first, the sends waiting to retry give up at once, their batches are spilled with Spill or dropped.
+func (w *Writer) Close(ctx context.Context) error {
+	w.init()
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.dataChan)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		// the sends waiting to retry give up, spilling their batch with Spill
+		w.stopRetriesOnce.Do(func() {
+			close(w.stopRetries)
+		})
+		return ctx.Err()
+	}
+	if w.spill == nil {
+		return nil
+	}
+	// the batches left on disk are sent by the next process
+	w.stopReplayOnce.Do(func() {
+		close(w.stopReplay)
+	})
+	select {
+	case <-w.replayDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// init only runs once. Keep all of our buffering in one thread
+func (w *Writer) init() {
+	w.once.Do(func() {
+		// synchronously set up dataChan
+		w.dataChan = make(chan *message, bufferSize)
+		// Spin up single goroutine to listen to our writes
+		w.errors = make(chan error, bufferSize)
+		w.done = make(chan struct{})
+		w.overflowed = make(chan struct{}, 1)
+		w.stopRetries = make(chan struct{})
+		w.inFlight = make(map[*batch]struct{})
+		if w.Spill != nil {
+			var err error
+			if w.spill, err = openSpill(w.Spill); err != nil {
+				// batches are dropped as without Spill
+				w.report(err)
+			} else {
+				w.stopReplay = make(chan struct{})
+				w.replayDone = make(chan struct{})
+				go w.replay()
+			}
+		}
+		go w.listen()
+	})
+}
+
+// replay sends the spilled batches every ReplayInterval, until Close
+func (w *Writer) replay() {
+	defer close(w.replayDone)
+	interval := w.Spill.ReplayInterval
+	if interval <= 0 {
+		interval = defaultSpillReplayInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopReplay:
+			return
+		case <-ticker.C:
+			if w.spill.empty() {
+				continue
+			}
+			if err := w.spill.replay(w.Client, w.report); err != nil {
+				w.report(err)
+			}
+		}
+	}
+}
+
 // listen for messages
 func (w *Writer) listen() {
 	if w.FlushInterval <= 0 {
@@ -77,55 +320,307 @@ func (w *Writer) listen() {
 	if w.FlushThreshold == 0 {
 		w.FlushThreshold = defaultThreshold
 	}
+	if w.MaxConcurrentSends <= 0 {
+		w.MaxConcurrentSends = defaultConcurrentSend
+	}
+	if w.MaxQueuedBatches <= 0 {
+		w.MaxQueuedBatches = defaultQueuedBatches
+	}
+	w.sendSlots = make(chan struct{}, w.MaxConcurrentSends)
 	ticker := time.NewTicker(w.FlushInterval)
+	defer ticker.Stop()
 	buffer := make([]*message, 0)
+	// queued holds the batches waiting for a free send slot, listen never waits for one
+	// so that a slow splunk can't block Write
+	queued := make([]*queuedBatch, 0)
 	//Define function so we can flush in several places
 	flush := func() {
-		// Go send the data to splunk
-		go w.send(buffer, w.MaxRetries)
+		b := &batch{done: make(chan struct{})}
+		w.inFlightMu.Lock()
+		w.inFlight[b] = struct{}{}
+		w.inFlightMu.Unlock()
+		w.sending.Add(1)
+		q := &queuedBatch{batch: b, messages: buffer}
+		if w.spill != nil {
+			// the segments of a batch are named after its place in the queue, whenever they are spilled
+			q.seq = w.spill.reserve()
+		}
+		queued = append(queued, q)
+		if len(queued) > w.MaxQueuedBatches {
+			w.giveUp(queued[0])
+			queued = queued[1:]
+		}
 		// Make a new array since the old one is getting used by the splunk client now
 		buffer = make([]*message, 0)
 	}
+	// start sends the oldest queued batch, once a send slot was taken for it
+	start := func() {
+		q := queued[0]
+		queued = queued[1:]
+		// Go send the data to splunk
+		go func() {
+			w.finish(q.batch, w.send(q.messages, q.seq, w.MaxRetries))
+			<-w.sendSlots
+		}()
+	}
+	// handle buffers a message, or answers a flush request
+	handle := func(d *message) {
+		if d.flushed != nil {
+			if len(buffer) > 0 {
+				flush()
+			}
+			d.flushed <- w.inFlightBatches()
+			return
+		}
+		buffer = append(buffer, d)
+		if len(buffer) > w.FlushThreshold {
+			flush()
+		}
+	}
+	// stop sends what is left and waits for the batches in flight
+	stop := func() {
+		for _, d := range w.takeOverflow() {
+			handle(d)
+		}
+		if len(buffer) > 0 {
+			flush()
+		}
+		for len(queued) > 0 {
+			w.sendSlots <- struct{}{}
+			start()
+		}
+		w.sending.Wait()
+		close(w.done)
+	}
 	for {
+		// only offered when a batch is waiting, a nil channel never receives
+		var sendSlots chan struct{}
+		if len(queued) > 0 {
+			sendSlots = w.sendSlots
+		}
 		select {
+		case sendSlots <- struct{}{}:
+			start()
 		case <-ticker.C:
 			if len(buffer) > 0 {
 				flush()
 			}
-		case d := <-w.dataChan:
-			buffer = append(buffer, d)
-			if len(buffer) > w.FlushThreshold {
-				flush()
+		case <-w.overflowed:
+			// the overflow was written after everything in dataChan, and nothing is added to dataChan until it is taken
+			for drained := false; !drained; {
+				select {
+				case d, isOpen := <-w.dataChan:
+					if !isOpen {
+						stop()
+						return
+					}
+					handle(d)
+				default:
+					drained = true
+				}
 			}
+			for _, d := range w.takeOverflow() {
+				handle(d)
+			}
+		case d, isOpen := <-w.dataChan:
+			if !isOpen {
+				// closed, the overflow holds the last messages
+				stop()
+				return
+			}
+			handle(d)
 		}
 	}
 }
 
-// send sends data to splunk, retrying upon failure
-func (w *Writer) send(messages []*message, retries int) {
+// queuedBatch is a batch waiting for a send slot
+type queuedBatch struct {
+	batch    *batch
+	messages []*message
+	// seq is the sequence number of the spilled segments of the batch
+	seq uint64
+}
+
+// giveUp spills a batch that overflowed the queue, or drops it without Spill
+func (w *Writer) giveUp(q *queuedBatch) {
+	if w.spill != nil {
+		err := w.spillBatch(q.messages, q.seq)
+		if err == nil {
+			w.finish(q.batch, nil)
+			return
+		}
+		w.report(err)
+	}
+	w.dropped.Add(int64(len(q.messages)))
+	w.report(ErrQueueFull)
+	w.finish(q.batch, ErrQueueFull)
+}
+
+// finish marks the batch as delivered or given up
+func (w *Writer) finish(b *batch, err error) {
+	b.err = err
+	w.inFlightMu.Lock()
+	delete(w.inFlight, b)
+	w.inFlightMu.Unlock()
+	close(b.done)
+	w.sending.Done()
+}
+
+func (w *Writer) inFlightBatches() []*batch {
+	w.inFlightMu.Lock()
+	defer w.inFlightMu.Unlock()
+	batches := make([]*batch, 0, len(w.inFlight))
+	for b := range w.inFlight {
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+// withClientDefaults fills the unset metadata of an event with the configuration of the Client
+func (w *Writer) withClientDefaults(e *Event) *Event {
+	if e.Host != "" && e.Source != "" && e.SourceType != "" && e.Index != "" {
+		return e
+	}
+	filled := *e
+	if filled.Host == "" {
+		filled.Host = w.Client.Hostname
+	}
+	if filled.Source == "" {
+		filled.Source = w.Client.Source
+	}
+	if filled.SourceType == "" {
+		filled.SourceType = w.Client.SourceType
+	}
+	if filled.Index == "" {
+		filled.Index = w.Client.Index
+	}
+	return &filled
+}
+
+// bodies serializes the messages into the requests of the raw endpoint and the events of the event endpoint
+func (w *Writer) bodies(messages []*message) (rawChunks [][]byte, encoded [][]byte, err error) {
 	// Create events from our data so we can send them to splunk
-	events := make([]*Event, len(messages))
-	for i, m := range messages {
-		// Use the configuration of the Client for the event
-		events[i] = w.Client.NewEventWithTime(m.writtenAt, m.data, w.Client.Source, w.Client.SourceType, w.Client.Index)
+	events := make([]*Event, 0, len(messages))
+	var lines [][]byte
+	for _, m := range messages {
+		switch {
+		case m.event != nil:
+			events = append(events, w.withClientDefaults(m.event))
+		case w.Raw:
+			lines = append(lines, m.data)
+		default:
+			// Use the configuration of the Client for the event
+			events = append(events, w.Client.NewEventWithTime(m.writtenAt, m.data, w.Client.Source, w.Client.SourceType, w.Client.Index))
+		}
 	}
-	// Send the events to splunk
-	err := w.Client.LogEvents(events)
-	// If we had any failures, retry as many times as they requested
+	if len(lines) > 0 {
+		rawChunks = w.Client.rawChunks(rawLinesBody(lines))
+	}
+	encoded, err = encodeEvents(events)
+	return rawChunks, encoded, err
+}
+
+// spillBatch writes the messages of the batch to the spill directory
+func (w *Writer) spillBatch(messages []*message, seq uint64) error {
+	rawChunks, encoded, err := w.bodies(messages)
 	if err != nil {
-		for i := 0; i < retries; i++ {
-			// retry
-			err = w.Client.LogEvents(events)
-			if err == nil {
-				return
+		return err
+	}
+	return w.spillBodies(seq, rawChunks, encoded)
+}
+
+func (w *Writer) spillBodies(seq uint64, rawChunks [][]byte, encoded [][]byte) error {
+	if len(rawChunks) > 0 {
+		if err := w.spill.add(seq, rawSegment, bytes.Join(rawChunks, nil)); err != nil {
+			return err
+		}
+	}
+	if len(encoded) > 0 {
+		return w.spill.add(seq, eventsSegment, joinEvents(encoded))
+	}
+	return nil
+}
+
+// send sends data to splunk, retrying upon failure, and returns the last error once the retries are exhausted.
+// A retry resumes after the requests that went through, the events Splunk rejected as malformed are reported and dropped.
+// With Spill, the batches that could not be delivered are spilled to disk, and so are the new ones
+// while spilled batches are waiting. Their segments are named after seq so that they reach Splunk in order.
+func (w *Writer) send(messages []*message, seq uint64, retries int) error {
+	rawChunks, encoded, err := w.bodies(messages)
+	if err != nil {
+		w.report(err)
+		w.dropped.Add(int64(len(messages)))
+		return err
+	}
+	if w.spill != nil && !w.spill.empty() {
+		if err = w.spillBodies(seq, rawChunks, encoded); err != nil {
+			w.report(err)
+			w.dropped.Add(int64(len(messages)))
+		}
+		return err
+	}
+	delivered := 0
+	deliver := func() error {
+		for len(rawChunks) > 0 {
+			if err := w.Client.sendRaw(rawChunks[0]); err != nil {
+				return err
 			}
+			// don't send the lines again when a later request fails
+			rawChunks = rawChunks[1:]
 		}
-		// if we've exhausted our max retries, let someone know via Errors()
-		// might not have retried if retries == 0
-		select {
-		case w.errors <- err:
-		// Don't block in case no one is listening or our errors channel is full
-		default:
+		done, rejected, err := w.Client.sendEncoded(encoded[delivered:], delivered)
+		delivered += done
+		for _, r := range rejected {
+			w.report(r)
+			w.dropped.Add(1)
 		}
+		return err
+	}
+	// Send the events to splunk
+	err = deliver()
+	// If we had any failures, retry as many times as they requested
+	for i := 0; err != nil && i < retries && isRetryable(err) && w.waitRetry(w.retryDelay(err, i)); i++ {
+		// retry once Splunk had time to recover
+		err = deliver()
+	}
+	if err == nil {
+		return nil
+	}
+	// if we've exhausted our max retries, let someone know via Errors()
+	// might not have retried if retries == 0
+	w.report(err)
+	if w.spill != nil && isRetryable(err) {
+		spillError := w.spillBodies(seq, rawChunks, encoded[delivered:])
+		if spillError == nil {
+			return nil
+		}
+		w.report(spillError)
+	}
+	lost := len(encoded) - delivered
+	for _, chunk := range rawChunks {
+		lost += bytes.Count(chunk, []byte("\n"))
+	}
+	w.dropped.Add(int64(lost))
+	return err
+}
+
+// waitRetry waits for the retry delay, it returns false when Close gave up waiting for the sends
+func (w *Writer) waitRetry(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-w.stopRetries:
+		return false
+	}
+}
+
+// report hands the error to Errors
+func (w *Writer) report(err error) {
+	select {
+	case w.errors <- err:
+	// Don't block in case no one is listening or our errors channel is full
+	default:
 	}
 }
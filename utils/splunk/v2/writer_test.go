@@ -1,12 +1,15 @@
 package splunk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -61,6 +64,8 @@ func TestWriter_Write(t *testing.T) {
 		t.Errorf("Timed out waiting for messages")
 	}
 	// We may have received more than numWrites amount of messages, check that case
+	lock.Lock()
+	defer lock.Unlock()
 	if numMessages != numWrites {
 		t.Errorf("Didn't get the right number of messages, expected %d, got %d", numWrites, numMessages)
 	}
@@ -89,3 +94,237 @@ func TestWriter_Errors(t *testing.T) {
 		t.Errorf("Timed out waiting for error, should have gotten 1 error")
 	}
 }
+
+func TestWriter_ErrorsBeforeWrite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "bad request")
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:        NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushInterval: 1 * time.Millisecond,
+	}
+	// Listening must be possible before anything has been written
+	errors := writer.Errors()
+	if errors == nil {
+		t.Fatalf("Expected an errors channel before the first write")
+	}
+	_, _ = writer.Write([]byte(`"some data"`))
+	select {
+	case <-errors:
+	case <-time.After(1 * time.Second):
+		t.Errorf("Timed out waiting for error, should have gotten 1 error")
+	}
+}
+
+func TestWriter_Close(t *testing.T) {
+	numMessages := 0
+	lock := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		numMessages += strings.Count(string(b), "{")
+		lock.Unlock()
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client: NewClient(server.Client(), server.URL, "", "", "", ""),
+		// Nothing would be sent without Close
+		FlushThreshold: 1000,
+		FlushInterval:  5 * time.Minute,
+	}
+	for i := 0; i < 5; i++ {
+		_, _ = writer.Write([]byte(`"some data"`))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if numMessages != 5 {
+		t.Errorf("Expected the buffered messages to be sent on close, got %d", numMessages)
+	}
+	if _, err := writer.Write([]byte(`"too late"`)); err != ErrWriterClosed {
+		t.Errorf("Expected ErrWriterClosed after Close, got %v", err)
+	}
+}
+
+func TestWriter_WriteEvent(t *testing.T) {
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:         NewClient(server.Client(), server.URL, "", "app", "json", "main"),
+		FlushThreshold: 1,
+		FlushInterval:  5 * time.Minute,
+	}
+	eventTime := time.Date(2024, 5, 3, 10, 20, 30, 500000000, time.UTC)
+	err := writer.WriteEvent(&Event{
+		Time:   EventTime{eventTime},
+		Index:  "errors",
+		Event:  map[string]interface{}{"message": "payment failed"},
+		Fields: map[string]interface{}{"level": "ERROR"},
+	})
+	if err != nil {
+		t.Fatalf("WriteEvent failed: %v", err)
+	}
+	// flushed with the first event, the threshold being exceeded
+	_ = writer.WriteEvent(&Event{Time: EventTime{eventTime}, Event: "second"})
+	select {
+	case body := <-bodies:
+		for _, expected := range []string{`"time":1714731630.500`, `"source":"app"`, `"sourcetype":"json"`, `"index":"errors"`,
+			`"event":{"message":"payment failed"}`, `"fields":{"level":"ERROR"}`} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %s in %s", expected, body)
+			}
+		}
+		if !strings.Contains(body, `"index":"main","event":"second"`) {
+			t.Errorf("Expected the index of the client for the event without one, got %s", body)
+		}
+	case <-time.After(1 * time.Second):
+		t.Errorf("Timed out waiting for the event")
+	}
+}
+
+func TestWriter_Flush(t *testing.T) {
+	numMessages := 0
+	lock := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		numMessages += strings.Count(string(b), "{")
+		lock.Unlock()
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:         NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushThreshold: 1000,
+		FlushInterval:  5 * time.Minute,
+	}
+	defer writer.Close(context.Background())
+	for i := 0; i < 5; i++ {
+		_, _ = writer.Write([]byte(`"some data"`))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lock.Lock()
+	if numMessages != 5 {
+		t.Errorf("Expected the buffered messages to be delivered on flush, got %d", numMessages)
+	}
+	lock.Unlock()
+	// the writer keeps working after a flush
+	_, _ = writer.Write([]byte(`"more data"`))
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if numMessages != 6 {
+		t.Errorf("Expected 6 messages after the second flush, got %d", numMessages)
+	}
+}
+
+func TestWriter_FlushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, `{"text":"Invalid data format","code":6}`)
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:         NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushThreshold: 1000,
+		FlushInterval:  5 * time.Minute,
+	}
+	defer writer.Close(context.Background())
+	_, _ = writer.Write([]byte(`"some data"`))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := writer.Flush(ctx)
+	var response *EventCollectorResponse
+	if !errors.As(err, &response) || response.Code != InvalidDataFormat {
+		t.Errorf("Expected the HEC error from Flush, got %v", err)
+	}
+}
+
+func TestWriter_MaxConcurrentSends(t *testing.T) {
+	var current, maximum atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		for {
+			m := maximum.Load()
+			if n <= m || maximum.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		current.Add(-1)
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:             NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushThreshold:     1,
+		FlushInterval:      5 * time.Minute,
+		MaxConcurrentSends: 2,
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = writer.Write([]byte(`"some data"`))
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if maximum.Load() != 2 {
+		t.Errorf("Expected at most 2 concurrent sends, got %d", maximum.Load())
+	}
+}
+
+func TestWriter_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	writer := Writer{
+		Client:             NewClient(server.Client(), server.URL, "", "", "", ""),
+		FlushThreshold:     1,
+		FlushInterval:      5 * time.Minute,
+		MaxConcurrentSends: 1,
+		MaxQueuedBatches:   2,
+	}
+	// with the only send stuck, the buffer must keep draining instead of blocking Write
+	start := time.Now()
+	for i := 0; i < 4*bufferSize; i++ {
+		if _, err := writer.Write([]byte(`"some data"`)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected Write not to wait for Splunk, took %v", elapsed)
+	}
+	if err := <-writer.Errors(); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull on Errors, got %v", err)
+	}
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := writer.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// at most the batch in flight and the queued ones are sent, two messages each
+	if dropped := writer.Dropped(); dropped < 4*bufferSize-6 {
+		t.Errorf("Expected the overflowing batches to be dropped, got %d", dropped)
+	}
+}